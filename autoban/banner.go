@@ -0,0 +1,133 @@
+package autoban
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/smarty/ip-filter"
+)
+
+// Banner turns observed bad events (failed logins, rejected requests, ...) into escalating temporary bans.
+type Banner struct {
+	lock       sync.Mutex
+	bans       *ipfilter.ExpiringFilter
+	offenders  map[netip.Prefix]*offender
+	threshold  int
+	window     time.Duration
+	escalation func(int) time.Duration
+	memory     time.Duration
+	ipv4Bits   int
+	ipv6Bits   int
+	now        func() time.Time
+}
+type offender struct {
+	events      []time.Time // within the window, oldest first
+	strikes     int
+	bannedUntil time.Time
+}
+
+func newBanner(bans *ipfilter.ExpiringFilter, config configuration) *Banner {
+	return &Banner{
+		bans:       bans,
+		offenders:  make(map[netip.Prefix]*offender),
+		threshold:  config.threshold,
+		window:     config.window,
+		escalation: config.escalation,
+		memory:     config.memory,
+		ipv4Bits:   config.ipv4Bits,
+		ipv6Bits:   config.ipv6Bits,
+		now:        config.now,
+	}
+}
+
+// Observe records a bad event from address and reports whether it triggered a ban of the address's prefix. A ban
+// only ever extends a rule the filter already holds for the prefix.
+func (this *Banner) Observe(address netip.Addr) bool {
+	key, ok := this.key(address)
+	if !ok {
+		return false
+	}
+
+	now := this.now()
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	item := this.offenders[key]
+	if item == nil {
+		item = &offender{}
+		this.offenders[key] = item
+	}
+
+	item.events = append(trimEvents(item.events, now.Add(-this.window)), now)
+	if len(item.events) < this.threshold {
+		return false
+	}
+
+	item.events = item.events[:0]
+	item.strikes++
+	item.bannedUntil = now.Add(this.escalation(item.strikes))
+	return this.bans.ExtendPrefix(key, item.bannedUntil) // a longer ban of the prefix, e.g. a manual one, stays
+}
+func (this *Banner) key(address netip.Addr) (netip.Prefix, bool) {
+	address = address.WithZone("").Unmap()
+
+	bits := this.ipv6Bits
+	if address.Is4() {
+		bits = this.ipv4Bits
+	}
+
+	prefix, err := address.Prefix(bits)
+	return prefix, err == nil
+}
+func trimEvents(events []time.Time, cutoff time.Time) []time.Time {
+	index := 0
+	for index < len(events) && !events[index].After(cutoff) {
+		index++
+	}
+	return append(events[:0], events[index:]...)
+}
+
+// Strikes returns how many times the prefix containing address has been banned and is still remembered.
+func (this *Banner) Strikes(address netip.Addr) int {
+	key, ok := this.key(address)
+	if !ok {
+		return 0
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if item := this.offenders[key]; item != nil {
+		return item.strikes
+	}
+	return 0
+}
+
+// Prune forgets offenders without recent events whose last ban ended more than the configured memory ago, and
+// returns how many were forgotten. Call it periodically to bound memory.
+func (this *Banner) Prune() int {
+	now := this.now()
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	pruned := 0
+	for key, item := range this.offenders {
+		item.events = trimEvents(item.events, now.Add(-this.window))
+		if len(item.events) == 0 && !now.Before(item.bannedUntil.Add(this.memory)) {
+			delete(this.offenders, key)
+			pruned++
+		}
+	}
+
+	return pruned
+}
+
+// Len returns the number of offenders being tracked.
+func (this *Banner) Len() int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return len(this.offenders)
+}
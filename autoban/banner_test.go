@@ -0,0 +1,127 @@
+package autoban
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/smarty/ip-filter"
+)
+
+func TestBansAfterThresholdWithinWindow(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	bans := ipfilter.NewExpiring(clock.Now)
+	banner := New(bans, Options.Threshold(3), Options.Window(time.Minute), Options.Clock(clock.Now))
+	address := netip.MustParseAddr("203.0.113.7")
+
+	Assert(t).That(banner.Observe(address)).Equals(false)
+	clock.now = clock.now.Add(time.Second * 50)
+	Assert(t).That(banner.Observe(address)).Equals(false)
+	clock.now = clock.now.Add(time.Second * 20) // the first event slides out of the window
+	Assert(t).That(banner.Observe(address)).Equals(false)
+	Assert(t).That(banner.Observe(address)).Equals(true)
+
+	Assert(t).That(bans.Contains("203.0.113.7")).Equals(true)
+	Assert(t).That(bans.Contains("203.0.113.8")).Equals(false)
+	Assert(t).That(banner.Strikes(address)).Equals(1)
+
+	clock.now = clock.now.Add(time.Minute * 15)
+	Assert(t).That(bans.Contains("203.0.113.7")).Equals(false)
+}
+func TestEscalatesRepeatOffenders(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	bans := ipfilter.NewExpiring(clock.Now)
+	banner := New(bans, Options.Threshold(1), Options.Clock(clock.Now),
+		Options.Escalation(Doubling(time.Minute, time.Minute*3)))
+	address := netip.MustParseAddr("203.0.113.7")
+
+	for _, expected := range []time.Duration{time.Minute, time.Minute * 2, time.Minute * 3, time.Minute * 3} {
+		Assert(t).That(banner.Observe(address)).Equals(true)
+
+		clock.now = clock.now.Add(expected - time.Second)
+		Assert(t).That(bans.Contains("203.0.113.7")).Equals(true)
+		clock.now = clock.now.Add(time.Second)
+		Assert(t).That(bans.Contains("203.0.113.7")).Equals(false)
+	}
+}
+func TestBansDoNotShortenExistingBans(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	bans := ipfilter.NewExpiring(clock.Now)
+	bans.Add("203.0.113.7/32", time.Time{})                  // a permanent manual ban
+	bans.Add("198.51.100.7/32", clock.now.Add(time.Hour*48)) // longer than the escalation
+	banner := New(bans, Options.Threshold(1), Options.Clock(clock.Now),
+		Options.Escalation(Doubling(time.Minute, time.Hour)))
+
+	Assert(t).That(banner.Observe(netip.MustParseAddr("203.0.113.7"))).Equals(true)
+	Assert(t).That(banner.Observe(netip.MustParseAddr("198.51.100.7"))).Equals(true)
+
+	clock.now = clock.now.Add(time.Hour * 24)
+	Assert(t).That(bans.Contains("203.0.113.7")).Equals(true)
+	Assert(t).That(bans.Contains("198.51.100.7")).Equals(true)
+}
+func TestAggregatesByPrefix(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	bans := ipfilter.NewExpiring(clock.Now)
+	banner := New(bans, Options.Threshold(2), Options.Aggregate(24, 64), Options.Clock(clock.Now))
+
+	Assert(t).That(banner.Observe(netip.MustParseAddr("2600:f0f0:2:3::1"))).Equals(false)
+	Assert(t).That(banner.Observe(netip.MustParseAddr("2600:f0f0:2:3:ffff::2"))).Equals(true)
+	Assert(t).That(banner.Observe(netip.MustParseAddr("::ffff:198.51.100.1"))).Equals(false)
+	Assert(t).That(banner.Observe(netip.MustParseAddr("198.51.100.200"))).Equals(true)
+	Assert(t).That(banner.Observe(netip.Addr{})).Equals(false)
+
+	Assert(t).That(bans.Contains("2600:f0f0:2:3::abcd")).Equals(true)
+	Assert(t).That(bans.Contains("2600:f0f0:2:4::1")).Equals(false)
+	Assert(t).That(bans.Contains("198.51.100.99")).Equals(true)
+}
+func TestPruneForgetsIdleOffenders(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	banner := New(ipfilter.NewExpiring(clock.Now), Options.Threshold(2), Options.Clock(clock.Now),
+		Options.Escalation(Doubling(time.Minute, time.Hour)), Options.Memory(time.Hour))
+	banned, quiet := netip.MustParseAddr("203.0.113.7"), netip.MustParseAddr("203.0.113.8")
+
+	banner.Observe(banned)
+	banner.Observe(banned)
+	banner.Observe(quiet)
+	Assert(t).That(banner.Prune()).Equals(0)
+
+	clock.now = clock.now.Add(time.Minute * 2)
+	Assert(t).That(banner.Prune()).Equals(1) // quiet: its only event left the window
+	Assert(t).That(banner.Strikes(banned)).Equals(1)
+
+	clock.now = clock.now.Add(time.Hour)
+	Assert(t).That(banner.Prune()).Equals(1)
+	Assert(t).That(banner.Strikes(banned)).Equals(0)
+	Assert(t).That(banner.Len()).Equals(0)
+}
+func TestDoubling(t *testing.T) {
+	policy := Doubling(time.Minute*15, time.Hour*24)
+	Assert(t).That(policy(1)).Equals(time.Minute * 15)
+	Assert(t).That(policy(3)).Equals(time.Hour)
+	Assert(t).That(policy(1000)).Equals(time.Hour * 24)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type fakeClock struct{ now time.Time }
+
+func (this *fakeClock) Now() time.Time { return this.now }
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type That struct{ t *testing.T }
+type Assertion struct {
+	*testing.T
+	actual interface{}
+}
+
+func Assert(t *testing.T) *That                       { return &That{t: t} }
+func (this *That) That(actual interface{}) *Assertion { return &Assertion{T: this.t, actual: actual} }
+
+func (this *Assertion) Equals(expected interface{}) {
+	this.Helper()
+	if !reflect.DeepEqual(this.actual, expected) {
+		this.Errorf("\nExpected: %#v\nActual:   %#v", expected, this.actual)
+	}
+}
@@ -0,0 +1,89 @@
+package autoban
+
+import (
+	"time"
+
+	"github.com/smarty/ip-filter"
+)
+
+type configuration struct {
+	threshold  int
+	window     time.Duration
+	escalation func(strikes int) time.Duration
+	memory     time.Duration
+	ipv4Bits   int
+	ipv6Bits   int
+	now        func() time.Time
+}
+
+// New creates a Banner that inserts bans into bans. Use the same clock for both.
+func New(bans *ipfilter.ExpiringFilter, options ...option) *Banner {
+	var config configuration
+	Options.apply(options...)(&config)
+	return newBanner(bans, config)
+}
+
+var Options singleton
+
+type singleton struct{}
+type option func(*configuration)
+
+// Threshold sets how many events within the window trigger a ban (default: 5).
+func (singleton) Threshold(value int) option {
+	return func(this *configuration) { this.threshold = value }
+}
+
+// Window sets the sliding window in which events are counted (default: 1 minute).
+func (singleton) Window(value time.Duration) option {
+	return func(this *configuration) { this.window = value }
+}
+
+// Escalation maps the number of bans an offender has received (1 for the first) to the duration of the next ban
+// (default: Doubling(15 minutes, 24 hours)).
+func (singleton) Escalation(value func(strikes int) time.Duration) option {
+	return func(this *configuration) { this.escalation = value }
+}
+
+// Memory sets how long an offender's strikes are remembered after its last ban ends (default: 24 hours).
+func (singleton) Memory(value time.Duration) option {
+	return func(this *configuration) { this.memory = value }
+}
+
+// Aggregate sets the prefix lengths that events are keyed and banned by (default: /32 for IPv4, /64 for IPv6).
+func (singleton) Aggregate(ipv4Bits, ipv6Bits int) option {
+	return func(this *configuration) { this.ipv4Bits, this.ipv6Bits = ipv4Bits, ipv6Bits }
+}
+
+// Clock sets the source of the current time (default: time.Now).
+func (singleton) Clock(value func() time.Time) option {
+	return func(this *configuration) { this.now = value }
+}
+
+func (singleton) apply(options ...option) option {
+	return func(this *configuration) {
+		for _, item := range Options.defaults(options...) {
+			item(this)
+		}
+	}
+}
+func (singleton) defaults(options ...option) []option {
+	return append([]option{
+		Options.Threshold(5),
+		Options.Window(time.Minute),
+		Options.Escalation(Doubling(time.Minute*15, time.Hour*24)),
+		Options.Memory(time.Hour * 24),
+		Options.Aggregate(32, 64),
+		Options.Clock(time.Now),
+	}, options...)
+}
+
+// Doubling returns an escalation policy that starts at initial and doubles with every strike, up to maximum.
+func Doubling(initial, maximum time.Duration) func(strikes int) time.Duration {
+	return func(strikes int) time.Duration {
+		duration := initial
+		for i := 1; i < strikes && duration < maximum; i++ {
+			duration *= 2
+		}
+		return min(duration, maximum)
+	}
+}
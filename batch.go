@@ -0,0 +1,103 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"runtime"
+	"sync"
+)
+
+// ContainsAddr checks a single address. Filters from this package, and filters with their own ContainsAddr method
+// (such as wrappers), are queried without formatting the address.
+func ContainsAddr(filter Filter, address netip.Addr) bool {
+	switch lookup := filter.(type) {
+	case numericFilter:
+		family, numericIP, ok := parseAddr(address)
+		return ok && lookup.contains(family, numericIP)
+	case AddrFilter:
+		return lookup.ContainsAddr(address)
+	default:
+		return address.IsValid() && filter.Contains(address.String())
+	}
+}
+
+// ContainsBatch writes the result for addresses[i] to out[i]; out must be at least as long as addresses.
+func ContainsBatch(filter Filter, addresses []netip.Addr, out []bool) {
+	out = out[:len(addresses)]
+
+	lookup, ok := filter.(numericFilter)
+	if !ok {
+		for i, address := range addresses {
+			out[i] = ContainsAddr(filter, address)
+		}
+		return
+	}
+
+	for i, address := range addresses {
+		family, numericIP, ok := parseAddr(address)
+		out[i] = ok && lookup.contains(family, numericIP)
+	}
+}
+
+// ContainsStringBatch writes the result for addresses[i] to out[i]; out must be at least as long as addresses.
+func ContainsStringBatch(filter Filter, addresses []string, out []bool) {
+	out = out[:len(addresses)]
+
+	lookup, ok := filter.(numericFilter)
+	if !ok {
+		for i, address := range addresses {
+			out[i] = filter.Contains(address)
+		}
+		return
+	}
+
+	for i, address := range addresses {
+		family, numericIP, ok := parseAddress(address)
+		out[i] = ok && lookup.contains(family, numericIP)
+	}
+}
+
+// ContainsBatchParallel behaves like ContainsBatch but splits large batches into contiguous chunks checked by up to
+// workers goroutines (GOMAXPROCS when workers <= 0). Small batches are checked on the calling goroutine.
+func ContainsBatchParallel(filter Filter, addresses []netip.Addr, out []bool, workers int) {
+	out = out[:len(addresses)]
+	splitBatch(len(addresses), workers, func(low, high int) {
+		ContainsBatch(filter, addresses[low:high], out[low:high])
+	})
+}
+
+// ContainsStringBatchParallel behaves like ContainsStringBatch but splits large batches the same way as
+// ContainsBatchParallel.
+func ContainsStringBatchParallel(filter Filter, addresses []string, out []bool, workers int) {
+	out = out[:len(addresses)]
+	splitBatch(len(addresses), workers, func(low, high int) {
+		ContainsStringBatch(filter, addresses[low:high], out[low:high])
+	})
+}
+
+func splitBatch(count, workers int, check func(low, high int)) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	workers = min(workers, count/minimumParallelBatch)
+	if workers <= 1 {
+		check(0, count)
+		return
+	}
+
+	var waiter sync.WaitGroup
+	waiter.Add(workers)
+
+	chunk := (count + workers - 1) / workers
+	for low := 0; low < count; low += chunk {
+		go func(low, high int) {
+			defer waiter.Done()
+			check(low, high)
+		}(low, min(low+chunk, count))
+	}
+
+	waiter.Wait()
+}
+
+// chunks smaller than this cost more to schedule than to check
+const minimumParallelBatch = 4096
@@ -0,0 +1,49 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func BenchmarkBatchLoopContains(b *testing.B) {
+	filter, values, _ := newBatchBenchmark()
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, value := range values {
+			_ = filter.Contains(value)
+		}
+	}
+}
+func BenchmarkBatchStrings(b *testing.B) {
+	filter, values, _ := newBatchBenchmark()
+	out := make([]bool, len(values))
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		ContainsStringBatch(filter, values, out)
+	}
+}
+func BenchmarkBatchAddrs(b *testing.B) {
+	filter, _, addresses := newBatchBenchmark()
+	out := make([]bool, len(addresses))
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		ContainsBatch(filter, addresses, out)
+	}
+}
+func BenchmarkBatchAddrsParallel(b *testing.B) {
+	filter, _, addresses := newBatchBenchmark()
+	out := make([]bool, len(addresses))
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		ContainsBatchParallel(filter, addresses, out, 0)
+	}
+}
+
+func newBatchBenchmark() (Filter, []string, []netip.Addr) {
+	values, addresses := sampleBatch(ipAddresses)
+	return New(ipAddresses...), values, addresses
+}
@@ -0,0 +1,81 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestContainsBatchMatchesContains(t *testing.T) {
+	rules := corpus()
+	values, addresses := sampleBatch(rules)
+
+	for name, filter := range map[string]Filter{
+		"tree":       New(rules...),
+		"compressed": NewCompressed(rules...),
+		"compiled":   NewCompiled(rules...),
+		"flat":       NewFlat(rules...),
+		"foreign":    foreignFilter{Filter: New(rules...)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			expected := make([]bool, len(values))
+			for i, value := range values {
+				expected[i] = filter.Contains(value)
+			}
+
+			actual := make([]bool, len(values))
+			ContainsBatch(filter, addresses, actual)
+			Assert(t).That(actual).Equals(expected)
+
+			actual = make([]bool, len(values))
+			ContainsStringBatch(filter, values, actual)
+			Assert(t).That(actual).Equals(expected)
+		})
+	}
+}
+func TestContainsBatchParallelMatchesContains(t *testing.T) {
+	values, addresses := sampleBatch(corpus())
+	filter := New(corpus()...)
+
+	expected := make([]bool, len(values))
+	ContainsStringBatch(filter, values, expected)
+
+	actual := make([]bool, len(values))
+	ContainsBatchParallel(filter, addresses, actual, 3)
+	Assert(t).That(actual).Equals(expected)
+
+	actual = make([]bool, len(values))
+	ContainsStringBatchParallel(filter, values, actual, 0)
+	Assert(t).That(actual).Equals(expected)
+}
+func TestSplitBatch(t *testing.T) {
+	Assert(t).That(recordSplitBatch(minimumParallelBatch*3+1, 5)).Equals([][2]int{
+		{0, minimumParallelBatch + 1},
+		{minimumParallelBatch + 1, minimumParallelBatch*2 + 2},
+		{minimumParallelBatch*2 + 2, minimumParallelBatch*3 + 1},
+	})
+	Assert(t).That(recordSplitBatch(minimumParallelBatch, 5)).Equals([][2]int{{0, minimumParallelBatch}})
+}
+func recordSplitBatch(count, workers int) (chunks [][2]int) {
+	var lock sync.Mutex
+	splitBatch(count, workers, func(low, high int) {
+		lock.Lock()
+		defer lock.Unlock()
+		chunks = append(chunks, [2]int{low, high})
+	})
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i][0] < chunks[j][0] })
+	return chunks
+}
+
+func sampleBatch(rules []string) ([]string, []netip.Addr) {
+	values := sampleAddresses(rules)
+	addresses := make([]netip.Addr, len(values))
+	for i, value := range values {
+		addresses[i] = netip.MustParseAddr(value)
+	}
+	return values, addresses
+}
+
+// foreignFilter hides the package-internal lookup of the filter it wraps.
+type foreignFilter struct{ Filter }
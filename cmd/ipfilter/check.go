@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/smarty/ip-filter"
+)
+
+// check prints each address with the most specific rule containing it and where that rule came from, or "-" when
+// no rule does. It exits with 0 when any address matched, 1 when none did and 2 when an address was invalid.
+func (this streams) check(arguments []string) int {
+	var input input
+	var paths []string
+
+	flags := this.flags("check", "-rules FILE [-rules FILE...] [address...]")
+	input.register(flags)
+	flags.Func("rules", `a rule file; repeat for more files ("-" reads stdin)`, func(path string) error {
+		paths = append(paths, path)
+		return nil
+	})
+	quiet := flags.Bool("q", false, "print nothing; only set the exit code")
+	if err := flags.Parse(arguments); err != nil {
+		return parseFailure(err)
+	}
+
+	if len(paths) == 0 {
+		fmt.Fprintln(this.stderr, "ipfilter check: at least one -rules file is required")
+		flags.Usage()
+		return exitError
+	}
+
+	rules, err := input.load(this.stdin, paths...)
+	if err != nil {
+		return this.fail("check", err)
+	}
+
+	policy, _ := ipfilter.NewPolicy(ipfilter.Allow)
+	for _, item := range rules {
+		_ = policy.Add(ipfilter.PolicyRule{Name: item.origin, Prefix: item.prefix, Action: ipfilter.Deny})
+	}
+
+	output := bufio.NewWriter(this.stdout)
+	defer func() { _ = output.Flush() }()
+
+	matched, invalid := false, false
+	lookup := func(text string) {
+		address, err := netip.ParseAddr(text)
+		if err != nil {
+			fmt.Fprintf(this.stderr, "ipfilter check: invalid address %q\n", text)
+			invalid = true
+			return
+		}
+
+		decision := policy.Evaluate(address.Unmap())
+		if decision.Rule != nil {
+			matched = true
+		}
+
+		if *quiet {
+			return
+		} else if decision.Rule == nil {
+			fmt.Fprintf(output, "%s\t-\n", text)
+		} else {
+			fmt.Fprintf(output, "%s\t%s\t%s\n", text, decision.Rule.Prefix, decision.Rule.Name)
+		}
+	}
+
+	if flags.NArg() > 0 {
+		for _, text := range flags.Args() {
+			lookup(text)
+		}
+	} else {
+		scanner := bufio.NewScanner(this.stdin)
+		for scanner.Scan() {
+			if text := strings.TrimSpace(scanner.Text()); len(text) > 0 {
+				lookup(text)
+			}
+		}
+		if err = scanner.Err(); err != nil {
+			return this.fail("check", err)
+		}
+	}
+
+	switch {
+	case invalid:
+		return exitError
+	case matched:
+		return exitSuccess
+	default:
+		return exitNegative
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/netip"
+
+	"github.com/smarty/ip-filter"
+)
+
+// aggregate prints the smallest set of prefixes covering the same address space as the rules.
+func (this streams) aggregate(arguments []string) int {
+	return this.rewrite("aggregate", arguments, func(rules []rule) []netip.Prefix {
+		return ipfilter.Aggregate(rulesText(rules)...)
+	})
+}
+
+// convert prints the rules in another format, in their original order; host bits are cleared.
+func (this streams) convert(arguments []string) int {
+	return this.rewrite("convert", arguments, func(rules []rule) (prefixes []netip.Prefix) {
+		for _, item := range rules {
+			prefixes = append(prefixes, item.prefix)
+		}
+		return prefixes
+	})
+}
+
+func (this streams) rewrite(command string, arguments []string, transform func([]rule) []netip.Prefix) int {
+	var input input
+	flags := this.flags(command, "[file...]")
+	input.register(flags)
+	output := flags.String("output", "lines", `output format: "lines", "json" or "csv"`)
+	if err := flags.Parse(arguments); err != nil {
+		return parseFailure(err)
+	}
+
+	if err := checkFormat(*output); err != nil {
+		return this.fail(command, err)
+	}
+
+	rules, err := input.load(this.stdin, inputPaths(flags)...)
+	if err != nil {
+		return this.fail(command, err)
+	}
+
+	if err = writeRules(this.stdout, *output, transform(rules)); err != nil {
+		return this.fail(command, err)
+	}
+
+	return exitSuccess
+}
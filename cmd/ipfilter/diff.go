@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+
+	"github.com/smarty/ip-filter"
+)
+
+// diff compares the address space of two rule lists, not their text: it prints "- prefix" for space only the old
+// list covers and "+ prefix" for space only the new list covers, in address order. It exits with 0 when the lists
+// cover the same space and 1 when they differ.
+func (this streams) diff(arguments []string) int {
+	var input input
+	flags := this.flags("diff", "OLD NEW")
+	input.register(flags)
+	if err := flags.Parse(arguments); err != nil {
+		return parseFailure(err)
+	}
+
+	if flags.NArg() != 2 {
+		fmt.Fprintln(this.stderr, "ipfilter diff: expected two rule files")
+		flags.Usage()
+		return exitError
+	}
+
+	before, err := input.load(this.stdin, flags.Arg(0))
+	if err != nil {
+		return this.fail("diff", err)
+	}
+	after, err := input.load(this.stdin, flags.Arg(1))
+	if err != nil {
+		return this.fail("diff", err)
+	}
+
+	previous, current := ipfilter.NewFlat(rulesText(before)...), ipfilter.NewFlat(rulesText(after)...)
+	removed, added := previous.Subtract(current).Prefixes(), current.Subtract(previous).Prefixes()
+
+	changed := len(removed)+len(added) > 0
+
+	output := bufio.NewWriter(this.stdout)
+	for len(removed) > 0 || len(added) > 0 {
+		// both lists are in address order and never overlap
+		if len(added) == 0 || len(removed) > 0 && removed[0].Addr().Less(added[0].Addr()) {
+			fmt.Fprintf(output, "- %s\n", removed[0])
+			removed = removed[1:]
+		} else {
+			fmt.Fprintf(output, "+ %s\n", added[0])
+			added = added[1:]
+		}
+	}
+	if err = output.Flush(); err != nil {
+		return this.fail("diff", err)
+	}
+
+	if changed {
+		return exitNegative
+	}
+	return exitSuccess
+}
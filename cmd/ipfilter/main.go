@@ -0,0 +1,117 @@
+// Command ipfilter works with rule lists from the shell:
+//
+//	ipfilter check -rules blocked.txt 10.1.2.3 2600:f0f0::1
+//	ipfilter aggregate blocked.txt
+//	ipfilter convert -format json -fields ip_prefix,ipv6_prefix -output lines ranges.json
+//	ipfilter diff yesterday.txt today.txt
+//	ipfilter stats blocked.txt
+//	ipfilter serve -config config.json -listen :8080
+//	ipfilter scan -rules tor.txt -log json -key client_ip -output annotated access.log
+//
+// Exit codes: 0 on success, 1 when check or scan matches nothing or diff finds differences, and 2 on usage and
+// input errors.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	os.Exit(streams{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}.run(os.Args[1:]))
+}
+
+// streams are the standard streams of one invocation, so that commands can run in-process from tests.
+type streams struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (this streams) run(arguments []string) int {
+	if len(arguments) == 0 {
+		this.usage(this.stderr)
+		return exitError
+	}
+
+	switch arguments[0] {
+	case "check":
+		return this.check(arguments[1:])
+	case "aggregate":
+		return this.aggregate(arguments[1:])
+	case "convert":
+		return this.convert(arguments[1:])
+	case "diff":
+		return this.diff(arguments[1:])
+	case "stats":
+		return this.stats(arguments[1:])
+	case "serve":
+		return this.serve(arguments[1:])
+	case "scan":
+		return this.scan(arguments[1:])
+	case "help", "-h", "-help", "--help":
+		this.usage(this.stdout)
+		return exitSuccess
+	default:
+		fmt.Fprintf(this.stderr, "ipfilter: unknown command %q\n", arguments[0])
+		this.usage(this.stderr)
+		return exitError
+	}
+}
+func (this streams) usage(writer io.Writer) {
+	fmt.Fprint(writer, `usage: ipfilter <command> [flags] [arguments]
+
+commands:
+  check      report the rule matching each address (from arguments or stdin)
+  aggregate  print the smallest set of prefixes covering the rules
+  convert    rewrite rules in another format
+  diff       print the address space added and removed between two lists
+  stats      print rule counts, covered addresses and memory per structure
+  serve      answer lookups over HTTP for the sources of a configuration file
+  scan       print the log lines whose client address matches (or not), or annotate them
+
+Run "ipfilter <command> -h" for the flags of a command.
+`)
+}
+
+// fail reports an error of the named command and returns the matching exit code.
+func (this streams) fail(command string, err error) int {
+	fmt.Fprintf(this.stderr, "ipfilter %s: %v\n", command, err)
+	return exitError
+}
+
+// flags returns the flag set of a command, printing errors and usage to stderr.
+func (this streams) flags(command, arguments string) *flag.FlagSet {
+	flags := flag.NewFlagSet("ipfilter "+command, flag.ContinueOnError)
+	flags.SetOutput(this.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(this.stderr, "usage: ipfilter %s [flags] %s\n\nflags:\n", command, arguments)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFailure is the exit code after flags.Parse fails: asking for help is not an error.
+func parseFailure(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitSuccess
+	}
+	return exitError
+}
+
+// inputPaths returns the files named after the flags, or stdin when there are none.
+func inputPaths(flags *flag.FlagSet) []string {
+	if flags.NArg() == 0 {
+		return []string{"-"}
+	}
+	return flags.Args()
+}
+
+const (
+	exitSuccess  = 0
+	exitNegative = 1 // check or scan matched nothing, or diff found differences
+	exitError    = 2
+)
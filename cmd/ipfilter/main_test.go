@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	rules := writeFile(t, "rules.txt", "10.0.0.0/8\n10.1.0.0/16 # more specific\n\n2600:f0f0::/48\n")
+
+	code, stdout, stderr := invoke("", "check", "-rules", rules, "10.1.2.3", "10.2.0.1", "8.8.8.8", "::ffff:10.9.9.9", "2600:f0f0::1")
+	Assert(t).That(code).Equals(exitSuccess)
+	Assert(t).That(stderr).Equals("")
+	Assert(t).That(stdout).Equals(strings.Join([]string{
+		"10.1.2.3\t10.1.0.0/16\t" + rules + ":2",
+		"10.2.0.1\t10.0.0.0/8\t" + rules + ":1",
+		"8.8.8.8\t-",
+		"::ffff:10.9.9.9\t10.0.0.0/8\t" + rules + ":1",
+		"2600:f0f0::1\t2600:f0f0::/48\t" + rules + ":4",
+	}, "\n") + "\n")
+}
+func TestCheckReadsAddressesFromStdin(t *testing.T) {
+	rules := writeFile(t, "rules.txt", "10.0.0.0/8\n")
+
+	code, stdout, _ := invoke("8.8.8.8\n\n 1.1.1.1 \n", "check", "-rules", rules)
+	Assert(t).That(code).Equals(exitNegative)
+	Assert(t).That(stdout).Equals("8.8.8.8\t-\n1.1.1.1\t-\n")
+
+	code, stdout, _ = invoke("10.0.0.1\n", "check", "-q", "-rules", rules)
+	Assert(t).That(code).Equals(exitSuccess)
+	Assert(t).That(stdout).Equals("")
+}
+func TestCheckFailures(t *testing.T) {
+	rules := writeFile(t, "rules.txt", "10.0.0.0/8\n")
+
+	code, _, stderr := invoke("", "check", "-rules", rules, "10.0.0.1", "bogus")
+	Assert(t).That(code).Equals(exitError)
+	Assert(t).That(stderr).Equals("ipfilter check: invalid address \"bogus\"\n")
+
+	code, _, stderr = invoke("", "check", "10.0.0.1")
+	Assert(t).That(code).Equals(exitError)
+	Assert(t).That(strings.HasPrefix(stderr, "ipfilter check: at least one -rules file is required\n")).Equals(true)
+
+	code, _, stderr = invoke("", "check", "-rules", writeFile(t, "bad.txt", "10.0.0.0/8\n10.0.0.0/33\n"), "10.0.0.1")
+	Assert(t).That(code).Equals(exitError)
+	Assert(t).That(strings.Contains(stderr, "bad.txt:2: invalid subnet bits: \"10.0.0.0/33\"")).Equals(true)
+
+	code, _, _ = invoke("", "check", "-rules", filepath.Join(t.TempDir(), "missing.txt"), "10.0.0.1")
+	Assert(t).That(code).Equals(exitError)
+}
+func TestAggregate(t *testing.T) {
+	code, stdout, _ := invoke("10.0.0.0/24\n10.0.1.0/24\n10.0.0.128/25\n192.168.0.0/16\n", "aggregate")
+	Assert(t).That(code).Equals(exitSuccess)
+	Assert(t).That(stdout).Equals("10.0.0.0/23\n192.168.0.0/16\n")
+
+	code, stdout, _ = invoke("", "aggregate", "-output", "json")
+	Assert(t).That(code).Equals(exitSuccess)
+	Assert(t).That(stdout).Equals("[]\n")
+}
+func TestConvert(t *testing.T) {
+	document := `{"prefixes": [{"ip_prefix": "10.0.0.1/8"}, {"ipv6_prefix": "2600:f0f0::/48"}], "syncToken": "1"}`
+
+	code, stdout, _ := invoke(document, "convert", "-format", "json", "-fields", "ip_prefix,ipv6_prefix", "-output", "csv")
+	Assert(t).That(code).Equals(exitSuccess)
+	Assert(t).That(stdout).Equals("prefix\n10.0.0.0/8\n2600:f0f0::/48\n")
+
+	code, stdout, _ = invoke(stdout, "convert", "-format", "csv", "-output", "json")
+	Assert(t).That(code).Equals(exitSuccess)
+	Assert(t).That(stdout).Equals("[\n  \"10.0.0.0/8\",\n  \"2600:f0f0::/48\"\n]\n")
+
+	code, stdout, _ = invoke(stdout, "convert", "-format", "json")
+	Assert(t).That(code).Equals(exitSuccess)
+	Assert(t).That(stdout).Equals("10.0.0.0/8\n2600:f0f0::/48\n")
+}
+func TestConvertFailures(t *testing.T) {
+	code, _, stderr := invoke("", "convert", "-output", "yaml")
+	Assert(t).That(code).Equals(exitError)
+	Assert(t).That(stderr).Equals("ipfilter convert: unknown format: \"yaml\" (expected \"lines\", \"json\" or \"csv\")\n")
+
+	code, _, stderr = invoke("prefix\n10.0.0.0/8\nbogus\n", "convert", "-format", "csv")
+	Assert(t).That(code).Equals(exitError)
+	Assert(t).That(stderr).Equals("ipfilter convert: stdin:3: missing subnet bits (expected address/bits): \"bogus\"\n")
+
+	code, _, _ = invoke(`{"ip_prefix": "10.0.0.0/8"}`, "convert", "-format", "json")
+	Assert(t).That(code).Equals(exitError)
+}
+func TestDiff(t *testing.T) {
+	previous := writeFile(t, "previous.txt", "10.0.0.0/8\n192.168.0.0/16\n2600:f0f0::/48\n")
+	current := writeFile(t, "current.txt", "10.0.0.0/9\n10.128.0.0/9\n8.8.8.0/24\n192.168.0.0/17\n")
+
+	code, stdout, _ := invoke("", "diff", previous, current)
+	Assert(t).That(code).Equals(exitNegative)
+	Assert(t).That(stdout).Equals("+ 8.8.8.0/24\n- 192.168.128.0/17\n- 2600:f0f0::/48\n")
+
+	code, stdout, _ = invoke("", "diff", previous, previous)
+	Assert(t).That(code).Equals(exitSuccess)
+	Assert(t).That(stdout).Equals("")
+
+	code, _, _ = invoke("", "diff", previous)
+	Assert(t).That(code).Equals(exitError)
+}
+func TestStats(t *testing.T) {
+	code, stdout, _ := invoke("10.0.0.0/24\n10.0.1.0/24\n192.168.1.1/32\n2600:f0f0::/48\n", "stats")
+	Assert(t).That(code).Equals(exitSuccess)
+
+	lines := strings.Split(stdout, "\n")
+	Assert(t).That(strings.Fields(lines[0])).Equals([]string{"rules", "4"})
+	Assert(t).That(strings.Fields(lines[1])).Equals([]string{"ipv4", "rules", "3"})
+	Assert(t).That(strings.Fields(lines[3])).Equals([]string{"ipv4", "addresses", "513"})
+	Assert(t).That(strings.Fields(lines[4])).Equals([]string{"ipv6", "/64", "networks", "65536"})
+	Assert(t).That(strings.Fields(lines[5])).Equals([]string{"aggregated", "prefixes", "3"})
+	Assert(t).That(strings.Fields(lines[7])).Equals([]string{"structure", "rules", "nodes", "bytes"})
+	Assert(t).That(strings.Fields(lines[8])[:2]).Equals([]string{"tree", "4"})
+}
+func TestUsage(t *testing.T) {
+	code, _, stderr := invoke("")
+	Assert(t).That(code).Equals(exitError)
+	Assert(t).That(strings.HasPrefix(stderr, "usage: ipfilter <command>")).Equals(true)
+
+	code, _, stderr = invoke("", "frobnicate")
+	Assert(t).That(code).Equals(exitError)
+	Assert(t).That(strings.HasPrefix(stderr, "ipfilter: unknown command \"frobnicate\"\n")).Equals(true)
+
+	code, stdout, _ := invoke("", "help")
+	Assert(t).That(code).Equals(exitSuccess)
+	Assert(t).That(strings.Contains(stdout, "aggregate")).Equals(true)
+
+	code, _, stderr = invoke("", "stats", "-h")
+	Assert(t).That(code).Equals(exitSuccess)
+	Assert(t).That(strings.HasPrefix(stderr, "usage: ipfilter stats [flags] [file...]")).Equals(true)
+
+	code, _, _ = invoke("", "stats", "-bogus")
+	Assert(t).That(code).Equals(exitError)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func invoke(stdin string, arguments ...string) (code int, stdout, stderr string) {
+	var output, errors bytes.Buffer
+	code = streams{stdin: strings.NewReader(stdin), stdout: &output, stderr: &errors}.run(arguments)
+	return code, output.String(), errors.String()
+}
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type That struct{ t *testing.T }
+type Assertion struct {
+	*testing.T
+	actual interface{}
+}
+
+func Assert(t *testing.T) *That                       { return &That{t: t} }
+func (this *That) That(actual interface{}) *Assertion { return &Assertion{T: this.t, actual: actual} }
+
+func (this *Assertion) Equals(expected interface{}) {
+	this.Helper()
+	if !reflect.DeepEqual(this.actual, expected) {
+		this.Errorf("\nExpected: %#v\nActual:   %#v", expected, this.actual)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/smarty/ip-filter"
+	"github.com/smarty/ip-filter/reload"
+)
+
+// rule is a parsed rule and where it came from, such as "blocked.txt:12".
+type rule struct {
+	prefix netip.Prefix
+	origin string
+}
+
+func rulesText(rules []rule) (texts []string) {
+	for _, item := range rules {
+		texts = append(texts, item.prefix.String())
+	}
+	return texts
+}
+
+// input holds the flags describing rule files.
+type input struct {
+	format string
+	fields string
+}
+
+func (this *input) register(flags *flag.FlagSet) {
+	flags.StringVar(&this.format, "format", "lines", `format of rule files: "lines", "json" or "csv"`)
+	flags.StringVar(&this.fields, "fields", "",
+		"comma-separated JSON fields holding rules, such as ip_prefix,ipv6_prefix (default: an array of rules)")
+}
+
+// load reads the rule files at paths, in order; the path "-" reads stdin. Any invalid rule fails the whole load.
+func (this input) load(stdin io.Reader, paths ...string) (rules []rule, err error) {
+	if err = checkFormat(this.format); err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		loaded, err := this.loadFile(stdin, path)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, loaded...)
+	}
+
+	return rules, nil
+}
+func (this input) loadFile(stdin io.Reader, path string) ([]rule, error) {
+	if path == "-" {
+		return this.read("stdin", stdin)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	return this.read(path, file)
+}
+func (this input) read(name string, reader io.Reader) ([]rule, error) {
+	switch this.format {
+	case "json":
+		return readJSON(name, reader, this.fields)
+	case "csv":
+		return readCSV(name, reader)
+	default:
+		return readLines(name, reader)
+	}
+}
+
+// readLines reads one rule per line; blank lines and everything after a '#' are ignored.
+func readLines(name string, reader io.Reader) (rules []rule, err error) {
+	scanner := bufio.NewScanner(reader)
+
+	for number := 1; scanner.Scan(); number++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		if text = strings.TrimSpace(text); len(text) == 0 {
+			continue
+		}
+
+		prefix, err := ipfilter.ParseRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w: %q", name, number, err, text)
+		}
+		rules = append(rules, rule{prefix: prefix, origin: fmt.Sprintf("%s:%d", name, number)})
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return rules, nil
+}
+
+// readJSON reads the named fields anywhere in the document, or a plain array of rules when no field is named.
+func readJSON(name string, reader io.Reader, fields string) (rules []rule, err error) {
+	var texts []string
+	if len(fields) > 0 {
+		texts, err = reload.ParseJSON(strings.Split(fields, ",")...)(reader)
+	} else {
+		err = json.NewDecoder(reader).Decode(&texts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	for _, text := range texts {
+		prefix, err := ipfilter.ParseRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w: %q", name, err, text)
+		}
+		rules = append(rules, rule{prefix: prefix, origin: name})
+	}
+
+	return rules, nil
+}
+
+// readCSV reads the rule in the first column of each record; a first record that holds no rule is a header.
+func readCSV(name string, reader io.Reader) (rules []rule, err error) {
+	records := csv.NewReader(reader)
+	records.FieldsPerRecord = -1
+	records.Comment = '#'
+
+	for first := true; ; first = false {
+		record, err := records.Read()
+		if err == io.EOF {
+			return rules, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		line, _ := records.FieldPos(0)
+		text := strings.TrimSpace(record[0])
+		prefix, err := ipfilter.ParseRule(text)
+		if err != nil && first {
+			continue // header
+		} else if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w: %q", name, line, err, text)
+		}
+
+		rules = append(rules, rule{prefix: prefix, origin: fmt.Sprintf("%s:%d", name, line)})
+	}
+}
+
+// writeRules writes prefixes in one of the formats that input reads back.
+func writeRules(writer io.Writer, format string, prefixes []netip.Prefix) error {
+	texts := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		texts = append(texts, prefix.String())
+	}
+
+	switch format {
+	case "json":
+		content, _ := json.MarshalIndent(texts, "", "  ")
+		_, err := fmt.Fprintf(writer, "%s\n", content)
+		return err
+	case "csv":
+		records := csv.NewWriter(writer)
+		_ = records.Write([]string{"prefix"})
+		for _, text := range texts {
+			_ = records.Write([]string{text})
+		}
+		records.Flush()
+		return records.Error()
+	default:
+		buffered := bufio.NewWriter(writer)
+		for _, text := range texts {
+			_, _ = buffered.WriteString(text + "\n")
+		}
+		return buffered.Flush()
+	}
+}
+
+func checkFormat(format string) error {
+	switch format {
+	case "lines", "json", "csv":
+		return nil
+	default:
+		return fmt.Errorf("%w: %q (expected \"lines\", \"json\" or \"csv\")", errUnknownFormat, format)
+	}
+}
+
+var errUnknownFormat = errors.New("unknown format")
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/smarty/ip-filter"
+	"github.com/smarty/ip-filter/config"
+	"github.com/smarty/ip-filter/scan"
+)
+
+// scan filters or annotates log lines by the rules matching their client address (see package scan). Each rule
+// file is a source named after its path. It exits with 0 when any line matched, 1 when none did and 2 on errors.
+func (this streams) scan(arguments []string) int {
+	var input input
+	var paths []string
+
+	flags := this.flags("scan", "(-rules FILE... | -config FILE) [log...]")
+	input.register(flags)
+	flags.Func("rules", "a rule file, reported as a source named after its path; repeat for more files",
+		func(path string) error {
+			paths = append(paths, path)
+			return nil
+		})
+	configPath := flags.String("config", "", "a configuration file whose enabled sources are matched (see package config)")
+	logFormat := flags.String("log", "combined", `log format: "combined", "json", "alb", "cloudfront" or "regexp"`)
+	key := flags.String("key", "remote_addr", `the key holding the client address, for -log json`)
+	pattern := flags.String("regexp", "", `the pattern capturing the client address (group "ip" or the first group), for -log regexp`)
+	output := flags.String("output", "matching", `lines to print: "matching", "non-matching" or "annotated"`)
+	if err := flags.Parse(arguments); err != nil {
+		return parseFailure(err)
+	}
+
+	extract, err := extractor(*logFormat, *key, *pattern)
+	if err != nil {
+		return this.fail("scan", err)
+	}
+	selected, err := scanOutput(*output)
+	if err != nil {
+		return this.fail("scan", err)
+	}
+	if (len(paths) == 0) == (len(*configPath) == 0) {
+		fmt.Fprintln(this.stderr, "ipfilter scan: either -rules or -config is required")
+		flags.Usage()
+		return exitError
+	}
+
+	matcher, err := this.matcher(input, paths, *configPath)
+	if err != nil {
+		return this.fail("scan", err)
+	}
+
+	scanner := scan.New(matcher, scan.Options.Format(extract), scan.Options.Output(selected))
+	matched := false
+	for _, path := range inputPaths(flags) {
+		summary, err := this.scanFile(scanner, path)
+		if err != nil {
+			return this.fail("scan", err)
+		}
+		matched = matched || summary.Matched > 0
+	}
+
+	if !matched {
+		return exitNegative
+	}
+	return exitSuccess
+}
+func (this streams) matcher(input input, paths []string, configPath string) (scan.Matcher, error) {
+	if len(configPath) > 0 {
+		settings, err := config.Load(configPath)
+		if err != nil {
+			return nil, err
+		}
+		engine, err := config.Build(settings)
+		if err != nil {
+			return nil, err
+		}
+		return engine.Composite(), nil
+	}
+
+	composite := ipfilter.NewComposite()
+	for _, path := range paths {
+		rules, err := input.load(this.stdin, path)
+		if err != nil {
+			return nil, err
+		}
+		composite.Set(path, rulesText(rules)...)
+	}
+
+	return composite, nil
+}
+func (this streams) scanFile(scanner *scan.Scanner, path string) (scan.Summary, error) {
+	var reader io.Reader = this.stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return scan.Summary{}, err
+		}
+		defer func() { _ = file.Close() }()
+		reader = file
+	}
+
+	return scanner.Scan(reader, this.stdout)
+}
+
+func extractor(format, key, pattern string) (scan.Extractor, error) {
+	switch format {
+	case "combined":
+		return scan.Combined(), nil
+	case "json":
+		return scan.JSON(key), nil
+	case "alb":
+		return scan.ALB(), nil
+	case "cloudfront":
+		return scan.CloudFront(), nil
+	case "regexp":
+		if len(pattern) == 0 {
+			return nil, errMissingPattern
+		}
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return scan.Regexp(compiled), nil
+	default:
+		return nil, fmt.Errorf("%w: %q (expected \"combined\", \"json\", \"alb\", \"cloudfront\" or \"regexp\")",
+			errUnknownFormat, format)
+	}
+}
+func scanOutput(value string) (scan.Output, error) {
+	switch value {
+	case "matching":
+		return scan.Matching, nil
+	case "non-matching":
+		return scan.NonMatching, nil
+	case "annotated":
+		return scan.Annotated, nil
+	default:
+		return 0, fmt.Errorf("%w: %q (expected \"matching\", \"non-matching\" or \"annotated\")", errUnknownOutput, value)
+	}
+}
+
+var (
+	errMissingPattern = errors.New("-log regexp needs -regexp")
+	errUnknownOutput  = errors.New("unknown output")
+)
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const accessLog = `10.1.2.3 - - [19/Oct/2026:13:55:36 -0700] "GET / HTTP/1.1" 200 2326
+8.8.8.8 - - [19/Oct/2026:13:55:37 -0700] "GET / HTTP/1.1" 200 2326
+`
+
+func TestScan(t *testing.T) {
+	rules := writeFile(t, "tor.txt", "10.0.0.0/8\n")
+	lines := strings.SplitAfter(accessLog, "\n")
+
+	code, stdout, _ := invoke(accessLog, "scan", "-rules", rules)
+	Assert(t).That(code).Equals(exitSuccess)
+	Assert(t).That(stdout).Equals(lines[0])
+
+	code, stdout, _ = invoke("", "scan", "-rules", rules, "-output", "annotated", writeFile(t, "access.log", accessLog))
+	Assert(t).That(code).Equals(exitSuccess)
+	Assert(t).That(stdout).Equals(rules + "\t10.0.0.0/8\t" + lines[0] + "-\t-\t" + lines[1])
+
+	code, stdout, _ = invoke(lines[1], "scan", "-rules", rules, "-output", "non-matching")
+	Assert(t).That(code).Equals(exitNegative)
+	Assert(t).That(stdout).Equals(lines[1])
+}
+func TestScanFormats(t *testing.T) {
+	rules := writeFile(t, "tor.txt", "10.0.0.0/8\n")
+
+	code, stdout, _ := invoke(`{"client_ip": "10.1.2.3"}`+"\n", "scan", "-rules", rules, "-log", "json", "-key", "client_ip")
+	Assert(t).That(code).Equals(exitSuccess)
+	Assert(t).That(stdout).Equals(`{"client_ip": "10.1.2.3"}` + "\n")
+
+	code, _, _ = invoke("level=warn client=10.1.2.3\n", "scan", "-rules", rules, "-log", "regexp", "-regexp", `client=(\S+)`)
+	Assert(t).That(code).Equals(exitSuccess)
+
+	code, _, _ = invoke("h2 2026-10-19T23:39:43Z app/lb/1 10.1.2.3:2817 10.0.0.1:80\n", "scan", "-rules", rules, "-log", "alb")
+	Assert(t).That(code).Equals(exitSuccess)
+
+	code, _, _ = invoke("2026-10-19\t21:02:18\tLHR62-C2\t2390282\t10.1.2.3\tGET\n", "scan", "-rules", rules, "-log", "cloudfront")
+	Assert(t).That(code).Equals(exitSuccess)
+}
+func TestScanWithConfiguration(t *testing.T) {
+	config := writeFile(t, "config.json", `{"sources": [
+		{"name": "tor", "rules": ["10.0.0.0/8"]},
+		{"name": "retired", "rules": ["8.0.0.0/8"], "disabled": true}
+	]}`)
+
+	code, stdout, _ := invoke(accessLog, "scan", "-config", config, "-output", "annotated")
+	Assert(t).That(code).Equals(exitSuccess)
+	Assert(t).That(strings.HasPrefix(stdout, "tor\t10.0.0.0/8\t10.1.2.3 ")).Equals(true)
+	Assert(t).That(strings.Contains(stdout, "-\t-\t8.8.8.8 ")).Equals(true)
+}
+func TestScanFailures(t *testing.T) {
+	rules := writeFile(t, "tor.txt", "10.0.0.0/8\n")
+
+	for _, test := range []struct {
+		arguments []string
+		stderr    string
+	}{
+		{arguments: []string{"scan"}, stderr: "ipfilter scan: either -rules or -config is required\n"},
+		{arguments: []string{"scan", "-rules", rules, "-config", rules}, stderr: "ipfilter scan: either -rules or -config is required\n"},
+		{arguments: []string{"scan", "-rules", rules, "-log", "syslog"}, stderr: `ipfilter scan: unknown format: "syslog"`},
+		{arguments: []string{"scan", "-rules", rules, "-log", "regexp"}, stderr: "ipfilter scan: -log regexp needs -regexp\n"},
+		{arguments: []string{"scan", "-rules", rules, "-log", "regexp", "-regexp", "("}, stderr: "ipfilter scan: error parsing regexp"},
+		{arguments: []string{"scan", "-rules", rules, "-output", "all"}, stderr: `ipfilter scan: unknown output: "all"`},
+		{arguments: []string{"scan", "-rules", writeFile(t, "bad.txt", "10.0.0.0/33\n")}, stderr: "ipfilter scan: "},
+		{arguments: []string{"scan", "-config", writeFile(t, "config.json", `{"sources": []}`)}, stderr: "ipfilter scan: "},
+		{arguments: []string{"scan", "-rules", rules, rules + ".missing"}, stderr: "ipfilter scan: open "},
+	} {
+		code, _, stderr := invoke("", test.arguments...)
+		Assert(t).That(code).Equals(exitError)
+		Assert(t).That(strings.HasPrefix(stderr, test.stderr)).Equals(true)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/smarty/ip-filter/service"
+)
+
+// serve answers lookups over HTTP (see package service) until interrupted; SIGHUP reloads the configuration file.
+// It exits with 2 when the configuration cannot be loaded or the address cannot be listened on.
+func (this streams) serve(arguments []string) int {
+	flags := this.flags("serve", "-config FILE")
+	path := flags.String("config", "", "the configuration file (see package config)")
+	address := flags.String("listen", "localhost:8080", "the address to listen on")
+	watch := flags.Duration("watch", 0, "how often to check the configuration file for changes (default: only on SIGHUP)")
+	if err := flags.Parse(arguments); err != nil {
+		return parseFailure(err)
+	}
+
+	if len(*path) == 0 {
+		fmt.Fprintln(this.stderr, "ipfilter serve: -config is required")
+		flags.Usage()
+		return exitError
+	}
+
+	logger := slog.New(slog.NewTextHandler(this.stderr, nil))
+	handler, err := service.New(*path, service.Options.Logger(logger), service.Options.Watch(*watch))
+	if err != nil {
+		return this.fail("serve", err)
+	}
+
+	listener, err := net.Listen("tcp", *address)
+	if err != nil {
+		return this.fail("serve", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	logger.Info("ipfilter: serving", slog.String("address", listener.Addr().String()))
+	if err = serveUntil(ctx, listener, handler, hangups); err != nil {
+		return this.fail("serve", err)
+	}
+
+	return exitSuccess
+}
+
+// serveUntil serves handler on listener until ctx is cancelled, reloading the configuration on every value received
+// from reloads, and then shuts down gracefully.
+func serveUntil(ctx context.Context, listener net.Listener, handler *service.Handler, reloads <-chan os.Signal) error {
+	var waiter sync.WaitGroup
+	defer waiter.Wait()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	waiter.Add(2)
+	go func() {
+		defer waiter.Done()
+		handler.Run(ctx)
+	}()
+	go func() {
+		defer waiter.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reloads:
+				_ = handler.Reload() // failures are logged and reported by /health
+			}
+		}
+	}()
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: time.Second * 10}
+	failed := make(chan error, 1)
+	go func() { failed <- server.Serve(listener) }()
+
+	select {
+	case err := <-failed:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdown, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+	return server.Shutdown(shutdown)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/smarty/ip-filter/service"
+)
+
+func TestServeUntilCancelled(t *testing.T) {
+	handler, err := service.New(writeFile(t, "config.json", `{"sources": [{"name": "tor", "rules": ["10.0.0.0/8"]}]}`))
+	Assert(t).That(err).Equals(nil)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Assert(t).That(err).Equals(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reloads := make(chan os.Signal, 1)
+	finished := make(chan error, 1)
+	go func() { finished <- serveUntil(ctx, listener, handler, reloads) }()
+
+	response, err := http.Get("http://" + listener.Addr().String() + "/check?ip=10.1.2.3")
+	Assert(t).That(err).Equals(nil)
+	body, _ := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+	Assert(t).That(strings.Contains(string(body), `"source":"tor"`)).Equals(true)
+
+	reloads <- syscall.SIGHUP
+	cancel()
+	Assert(t).That(<-finished).Equals(nil)
+}
+func TestServeFailures(t *testing.T) {
+	code, _, stderr := invoke("", "serve")
+	Assert(t).That(code).Equals(exitError)
+	Assert(t).That(strings.HasPrefix(stderr, "ipfilter serve: -config is required\n")).Equals(true)
+
+	code, _, stderr = invoke("", "serve", "-config", writeFile(t, "config.json", `{"sources": []}`))
+	Assert(t).That(code).Equals(exitError)
+	Assert(t).That(strings.Contains(stderr, "at least one source is required")).Equals(true)
+
+	config := writeFile(t, "config.json", `{"sources": [{"name": "tor", "rules": ["10.0.0.0/8"]}]}`)
+	code, _, _ = invoke("", "serve", "-config", config, "-listen", "256.0.0.1:0")
+	Assert(t).That(code).Equals(exitError)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/smarty/ip-filter"
+)
+
+// stats prints how many rules were read, how much address space they cover and what each structure in the package
+// would hold for them.
+func (this streams) stats(arguments []string) int {
+	var input input
+	flags := this.flags("stats", "[file...]")
+	input.register(flags)
+	if err := flags.Parse(arguments); err != nil {
+		return parseFailure(err)
+	}
+
+	rules, err := input.load(this.stdin, inputPaths(flags)...)
+	if err != nil {
+		return this.fail("stats", err)
+	}
+
+	texts := rulesText(rules)
+	ipv4Rules := 0
+	for _, item := range rules {
+		if item.prefix.Addr().Is4() {
+			ipv4Rules++
+		}
+	}
+	flat := ipfilter.NewFlat(texts...)
+	ipv4Addresses, ipv6Networks := flat.Coverage()
+
+	output := tabwriter.NewWriter(this.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(output, "rules\t%d\n", len(rules))
+	fmt.Fprintf(output, "ipv4 rules\t%d\n", ipv4Rules)
+	fmt.Fprintf(output, "ipv6 rules\t%d\n", len(rules)-ipv4Rules)
+	fmt.Fprintf(output, "ipv4 addresses\t%d\n", ipv4Addresses)
+	fmt.Fprintf(output, "ipv6 /64 networks\t%d\n", ipv6Networks)
+	fmt.Fprintf(output, "aggregated prefixes\t%d\n", len(flat.Prefixes()))
+
+	fmt.Fprintf(output, "\nstructure\trules\tnodes\tbytes\n")
+	for _, structure := range []struct {
+		name   string
+		filter interface{ Stats() ipfilter.Stats }
+	}{
+		{name: "tree", filter: ipfilter.New(texts...).(interface{ Stats() ipfilter.Stats })},
+		{name: "compressed", filter: ipfilter.NewCompressed(texts...).(interface{ Stats() ipfilter.Stats })},
+		{name: "compiled", filter: ipfilter.NewCompiled(texts...)},
+		{name: "flat", filter: flat},
+	} {
+		stats := structure.filter.Stats()
+		fmt.Fprintf(output, "%s\t%d\t%d\t%d\n", structure.name, stats.Rules(), stats.Nodes, stats.Bytes)
+	}
+
+	if err = output.Flush(); err != nil {
+		return this.fail("stats", err)
+	}
+
+	return exitSuccess
+}
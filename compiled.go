@@ -0,0 +1,162 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"sort"
+)
+
+// CompiledFilter is a read-only multibit trie built with controlled prefix expansion: every rule is expanded
+// to the stride boundary below it, so a lookup is at most one table read per stride (3 for IPv4, 7 for IPv6)
+// instead of one pointer dereference per bit. The first stride is 16 bits wide (a 2^16 entry root table) and
+// every following stride is 8 bits wide.
+type CompiledFilter struct {
+	families [2]strideTable
+	rules    [2]int
+}
+type strideTable struct {
+	strides []int
+	entries []uint32 // 0: no match, 1: match, otherwise: the index of the first entry of the child table
+}
+
+func NewCompiled(addresses ...string) *CompiledFilter {
+	this := &CompiledFilter{families: [2]strideTable{
+		ipv4Child: {strides: ipv4Strides},
+		ipv6Child: {strides: ipv6Strides},
+	}}
+
+	var rules []compiledRule
+	for _, item := range addresses {
+		if family, numericIP, subnetBits, ok := parseSubnetMask(item); ok {
+			rules = append(rules, compiledRule{family: family, numericIP: numericIP, subnetBits: subnetBits})
+		}
+	}
+
+	// shorter rules first: longer rules below an expanded entry are skipped instead of allocating dead tables
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].subnetBits < rules[j].subnetBits })
+
+	for _, rule := range rules {
+		if this.families[rule.family].add(maskNumericIP(rule.numericIP, rule.subnetBits), rule.subnetBits) {
+			this.rules[rule.family]++
+		}
+	}
+
+	return this
+}
+
+type compiledRule struct {
+	family     int
+	numericIP  uint64
+	subnetBits int
+}
+
+// add reports whether the rule changed the table, i.e. it was not already covered by another rule.
+func (this *strideTable) add(numericIP uint64, subnetBits int) (changed bool) {
+	if len(this.entries) == 0 {
+		this.allocate(this.strides[0])
+	}
+
+	table, consumed := 0, 0
+	for level, stride := range this.strides {
+		index := table + int(numericIP<<consumed>>(numericBitCount-stride))
+
+		if subnetBits <= consumed+stride {
+			span := 1 << (consumed + stride - subnetBits)
+			for i := index; i < index+span; i++ {
+				changed = changed || this.entries[i] != strideEntryMatch
+				this.entries[i] = strideEntryMatch
+			}
+			return changed
+		}
+
+		switch entry := this.entries[index]; entry {
+		case strideEntryMatch:
+			return false
+		case strideEntryEmpty:
+			table = this.allocate(this.strides[level+1])
+			this.entries[index] = uint32(table)
+		default:
+			table = int(entry)
+		}
+
+		consumed += stride
+	}
+
+	return false
+}
+func (this *strideTable) allocate(stride int) int {
+	table := len(this.entries)
+	this.entries = append(this.entries, make([]uint32, 1<<stride)...)
+	return table
+}
+
+func (this *CompiledFilter) Contains(ipAddress string) bool {
+	family, numericIP, ok := parseAddress(ipAddress)
+	return ok && this.contains(family, numericIP)
+}
+func (this *CompiledFilter) ContainsAddr(address netip.Addr) bool {
+	family, numericIP, ok := parseAddr(address)
+	return ok && this.contains(family, numericIP)
+}
+func (this *CompiledFilter) contains(family int, numericIP uint64) bool {
+	return this.families[family].contains(numericIP)
+}
+
+// ContainsIPv4 checks an IPv4 address held as a host-order integer, e.g. binary.BigEndian.Uint32(packet[12:16]).
+func (this *CompiledFilter) ContainsIPv4(numericIP uint32) bool {
+	table := &this.families[ipv4Child]
+	if numericIP == 0 || len(table.entries) == 0 {
+		return false
+	}
+
+	entry := table.entries[numericIP>>16]
+	if entry > strideEntryMatch {
+		entry = table.entries[entry+(numericIP>>8&0xFF)]
+	}
+	if entry > strideEntryMatch {
+		entry = table.entries[entry+(numericIP&0xFF)]
+	}
+
+	return entry == strideEntryMatch
+}
+
+func (this *strideTable) contains(numericIP uint64) bool {
+	if len(this.entries) == 0 {
+		return false
+	}
+
+	table, consumed := 0, 0
+	for _, stride := range this.strides {
+		entry := this.entries[table+int(numericIP<<consumed>>(numericBitCount-stride))]
+		if entry <= strideEntryMatch {
+			return entry == strideEntryMatch
+		}
+
+		table = int(entry)
+		consumed += stride
+	}
+
+	return false
+}
+
+func (this *CompiledFilter) Stats() Stats {
+	stats := Stats{IPv4Rules: this.rules[ipv4Child], IPv6Rules: this.rules[ipv6Child]}
+
+	for _, table := range this.families {
+		if len(table.entries) > 0 {
+			stats.Nodes += 1 + (len(table.entries)-1<<table.strides[0])/(1<<table.strides[1])
+		}
+		stats.Bytes += cap(table.entries) * 4
+	}
+
+	return stats
+}
+
+var (
+	ipv4Strides = []int{16, 8, 8}
+	ipv6Strides = []int{16, 8, 8, 8, 8, 8, 8}
+)
+
+const (
+	strideEntryEmpty = 0
+	strideEntryMatch = 1
+)
@@ -0,0 +1,45 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func BenchmarkCompiledTest(b *testing.B) {
+	filter := NewCompiled(ipAddresses...)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		_ = filter.Contains("1.2.3.4")
+	}
+}
+func BenchmarkCompiledAddr(b *testing.B) {
+	filter := NewCompiled(ipAddresses...)
+	address := netip.MustParseAddr("52.93.126.244")
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		_ = filter.ContainsAddr(address)
+	}
+}
+func BenchmarkCompiledIPv4(b *testing.B) {
+	filter := NewCompiled(ipAddresses...)
+	addresses := make([]uint32, 1024)
+	for i := range addresses {
+		addresses[i] = uint32(i) * 2654435761 // spread across the whole address space
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		_ = filter.ContainsIPv4(addresses[n&1023])
+	}
+}
+func BenchmarkCompiledBuild(b *testing.B) {
+	benchmarkBuild(b, func(addresses ...string) Filter { return NewCompiled(addresses...) })
+}
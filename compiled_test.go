@@ -0,0 +1,53 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestCompiledErrors(t *testing.T) {
+	filter := NewCompiled("", "10.0.0.0", "10.0/8", "0.0.0.0/10", "2600:h0h0:2::/48", ":::::/64")
+
+	assertNotContains(t, filter, "", "hello, world!", "10.0.0.1", "0.0.0.0", "2600:f0f0:2::1", "::::")
+	Assert(t).That(filter.ContainsIPv4(0)).Equals(false)
+	Assert(t).That(filter.ContainsAddr(netip.Addr{})).Equals(false)
+}
+func TestCompiledExpandsAcrossStrides(t *testing.T) {
+	filter := NewCompiled(
+		"10.0.0.0/7",       // expanded inside the root table
+		"54.168.0.0/16",    // exactly one root entry
+		"150.222.10.0/23",  // expanded inside a second level table
+		"52.93.126.244/30", // expanded inside a third level table
+		"2600:f0f0:2::/47",
+		"2a01:578:0:7301::1/128",
+	)
+
+	assertContains(t, filter, "10.0.0.1", "11.255.255.255", "54.168.1.1", "150.222.11.255", "52.93.126.247",
+		"2600:f0f0:3::1", "2a01:578:0:7301::")
+	assertNotContains(t, filter, "12.0.0.0", "54.169.0.0", "150.222.12.0", "52.93.126.248",
+		"2600:f0f0:4::1", "2a01:578:0:7302::")
+
+	Assert(t).That(filter.ContainsIPv4(0x36A80101)).Equals(true)  // 54.168.1.1
+	Assert(t).That(filter.ContainsIPv4(0x36A90000)).Equals(false) // 54.169.0.0
+	Assert(t).That(filter.ContainsAddr(netip.MustParseAddr("52.93.126.244"))).Equals(true)
+	Assert(t).That(filter.ContainsAddr(netip.MustParseAddr("2600:f0f0:3::1"))).Equals(true)
+}
+func TestCompiledMatchesTree(t *testing.T) {
+	rules := append([]string{
+		"2600:f0f0:2::/48",
+		"2600:1ff6:7400::/40",
+		"2605:9cc0:1ff0:600::/56",
+		"2a01:578:0:7301::1/128",
+		"3.0.0.0/9", // inserted before its longer siblings in the corpus
+	}, corpus()...)
+	tree, compiled := New(rules...), NewCompiled(rules...)
+	values := sampleAddresses(rules)
+
+	assertSameAnswers(t, tree, compiled, values...)
+
+	for _, value := range values {
+		if address, err := netip.ParseAddr(value); err == nil && tree.Contains(value) != compiled.ContainsAddr(address) {
+			t.Errorf("%s: expected %t", value, tree.Contains(value))
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// Composite merges named rule sets (sources) into a single trie and reports which sources matched an address. Every
+// change to a source rebuilds the merged trie, which is then swapped in atomically; lookups take no locks.
+type Composite struct {
+	lock    sync.Mutex // serializes changes
+	sources []*compositeSource
+	merged  atomic.Pointer[mergedSources]
+	now     func() time.Time
+}
+type compositeSource struct {
+	name     string
+	enabled  bool
+	prefixes []compositePrefix
+	updated  time.Time
+}
+type compositePrefix struct {
+	family     int
+	numericIP  uint64
+	subnetBits int
+}
+
+// mergedSources is an immutable build of the enabled sources; each prefix holds the indexes of its sources.
+type mergedSources struct {
+	rules valueTrie[[]int]
+	names []string
+}
+
+func NewComposite() *Composite {
+	this := &Composite{now: time.Now}
+	this.merged.Store(&mergedSources{})
+	return this
+}
+
+// Set replaces the rules of the named source, adding it (enabled) when it is new.
+func (this *Composite) Set(name string, rules ...string) {
+	prefixes := make([]compositePrefix, 0, len(rules))
+	for _, rule := range rules {
+		if family, numericIP, subnetBits, ok := parseSubnetMask(rule); ok {
+			prefixes = append(prefixes, compositePrefix{family: family, numericIP: numericIP, subnetBits: subnetBits})
+		}
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	source := this.find(name)
+	if source == nil {
+		source = &compositeSource{name: name, enabled: true}
+		this.sources = append(this.sources, source)
+	}
+
+	source.prefixes, source.updated = prefixes, this.now()
+	this.rebuild()
+}
+
+// Remove drops the named source and reports whether it existed.
+func (this *Composite) Remove(name string) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for i, source := range this.sources {
+		if source.name == name {
+			this.sources = append(this.sources[:i:i], this.sources[i+1:]...)
+			this.rebuild()
+			return true
+		}
+	}
+
+	return false
+}
+
+// Enable includes the named source in lookups again and reports whether it exists.
+func (this *Composite) Enable(name string) bool { return this.setEnabled(name, true) }
+
+// Disable leaves the named source out of lookups, keeping its rules, and reports whether it exists.
+func (this *Composite) Disable(name string) bool { return this.setEnabled(name, false) }
+
+func (this *Composite) setEnabled(name string, enabled bool) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	source := this.find(name)
+	if source == nil {
+		return false
+	}
+
+	if source.enabled != enabled {
+		source.enabled = enabled
+		this.rebuild()
+	}
+
+	return true
+}
+func (this *Composite) find(name string) *compositeSource {
+	for _, source := range this.sources {
+		if source.name == name {
+			return source
+		}
+	}
+
+	return nil
+}
+func (this *Composite) rebuild() {
+	merged := &mergedSources{}
+
+	for _, source := range this.sources {
+		if !source.enabled {
+			continue
+		}
+
+		index := len(merged.names)
+		merged.names = append(merged.names, source.name)
+
+		for _, prefix := range source.prefixes {
+			indexes, _ := merged.rules.insert(prefix.family, prefix.numericIP, prefix.subnetBits)
+			if count := len(*indexes); count == 0 || (*indexes)[count-1] != index {
+				*indexes = append(*indexes, index) // duplicates within a source are listed once
+			}
+		}
+	}
+
+	this.merged.Store(merged)
+}
+
+func (this *Composite) Contains(ipAddress string) bool {
+	family, numericIP, ok := parseAddress(ipAddress)
+	return ok && this.contains(family, numericIP)
+}
+func (this *Composite) ContainsAddr(address netip.Addr) bool {
+	family, numericIP, ok := parseAddr(address)
+	return ok && this.contains(family, numericIP)
+}
+func (this *Composite) contains(family int, numericIP uint64) (matched bool) {
+	this.merged.Load().rules.walk(family, numericIP, func(int, *[]int) bool {
+		matched = true
+		return false
+	})
+	return matched
+}
+
+// Match returns the names of the enabled sources with a rule containing the address, in the order the sources were
+// added; it is empty when nothing matched.
+func (this *Composite) Match(ipAddress string) []string {
+	family, numericIP, ok := parseAddress(ipAddress)
+	if !ok {
+		return nil
+	}
+
+	return this.match(family, numericIP)
+}
+func (this *Composite) MatchAddr(address netip.Addr) []string {
+	family, numericIP, ok := parseAddr(address)
+	if !ok {
+		return nil
+	}
+
+	return this.match(family, numericIP)
+}
+func (this *Composite) match(family int, numericIP uint64) (names []string) {
+	merged := this.merged.Load()
+
+	matched := make([]bool, len(merged.names))
+	merged.rules.walk(family, numericIP, func(_ int, indexes *[]int) bool {
+		for _, index := range *indexes {
+			matched[index] = true
+		}
+		return true
+	})
+
+	for index, name := range merged.names {
+		if matched[index] {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// SourceMatch is an enabled source with a rule containing an address, and the longest such rule.
+type SourceMatch struct {
+	Name   string
+	Prefix netip.Prefix
+}
+
+// Lookup is MatchAddr that also reports, for each matching source, its most specific rule containing the address.
+func (this *Composite) Lookup(address netip.Addr) (matches []SourceMatch) {
+	family, numericIP, ok := parseAddr(address)
+	if !ok {
+		return nil
+	}
+
+	merged := this.merged.Load()
+
+	longest := make([]int, len(merged.names)) // subnet bits; rules are never /0
+	merged.rules.walk(family, numericIP, func(subnetBits int, indexes *[]int) bool {
+		for _, index := range *indexes {
+			longest[index] = subnetBits // visited from shortest to longest
+		}
+		return true
+	})
+
+	for index, name := range merged.names {
+		if subnetBits := longest[index]; subnetBits > 0 {
+			matches = append(matches, SourceMatch{Name: name, Prefix: formatPrefix(family, numericIP, subnetBits)})
+		}
+	}
+
+	return matches
+}
+
+// SourceInfo describes one source of a Composite.
+type SourceInfo struct {
+	Name    string
+	Enabled bool
+	Rules   int // valid rules, including duplicates
+	Updated time.Time
+}
+
+// Sources describes every source in the order they were added.
+func (this *Composite) Sources() []SourceInfo {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	sources := make([]SourceInfo, 0, len(this.sources))
+	for _, source := range this.sources {
+		sources = append(sources, SourceInfo{
+			Name: source.name, Enabled: source.enabled, Rules: len(source.prefixes), Updated: source.updated,
+		})
+	}
+
+	return sources
+}
+
+// Stats counts the distinct prefixes of the enabled sources.
+func (this *Composite) Stats() Stats {
+	merged := this.merged.Load()
+	stats := merged.rules.stats(int(unsafe.Sizeof(valueNode[[]int]{})))
+	merged.rules.each(func(_ int, _ uint64, _ int, indexes *[]int) { stats.Bytes += cap(*indexes) * 8 })
+	return stats
+}
@@ -0,0 +1,112 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCompositeReportsMatchingSources(t *testing.T) {
+	filter := NewComposite()
+	filter.Set("cloud", "10.0.0.0/8", "2600:f0f0:2::/48")
+	filter.Set("tor", "10.1.2.3/32", "11.0.0.0/8", "random name", "11.0.0.0/8")
+	filter.Set("internal", "10.1.0.0/16")
+
+	Assert(t).That(filter.Match("10.1.2.3")).Equals([]string{"cloud", "tor", "internal"})
+	Assert(t).That(filter.Match("10.1.9.9")).Equals([]string{"cloud", "internal"})
+	Assert(t).That(filter.MatchAddr(netip.MustParseAddr("11.1.2.3"))).Equals([]string{"tor"})
+	Assert(t).That(filter.MatchAddr(netip.MustParseAddr("2600:f0f0:2::1"))).Equals([]string{"cloud"})
+	Assert(t).That(filter.Match("12.1.2.3") == nil).Equals(true)
+	Assert(t).That(filter.Match("not an address") == nil).Equals(true)
+	Assert(t).That(filter.MatchAddr(netip.Addr{}) == nil).Equals(true)
+
+	assertContains(t, filter, "10.1.2.3", "11.1.2.3", "2600:f0f0:2::1")
+	assertNotContains(t, filter, "12.1.2.3", "2600:f0f0:3::1", "")
+	Assert(t).That(filter.ContainsAddr(netip.MustParseAddr("11.1.2.3"))).Equals(true)
+	Assert(t).That(filter.Stats().Rules()).Equals(5)
+}
+func TestCompositeLookupReportsTheLongestRuleOfEachSource(t *testing.T) {
+	filter := NewComposite()
+	filter.Set("cloud", "10.0.0.0/8", "10.1.0.0/16", "2600:f0f0:2::/48")
+	filter.Set("tor", "10.1.2.3/32")
+	filter.Set("internal", "10.0.0.0/8")
+
+	Assert(t).That(filter.Lookup(netip.MustParseAddr("10.1.2.3"))).Equals([]SourceMatch{
+		{Name: "cloud", Prefix: netip.MustParsePrefix("10.1.0.0/16")},
+		{Name: "tor", Prefix: netip.MustParsePrefix("10.1.2.3/32")},
+		{Name: "internal", Prefix: netip.MustParsePrefix("10.0.0.0/8")},
+	})
+	Assert(t).That(filter.Lookup(netip.MustParseAddr("2600:f0f0:2::1"))).Equals([]SourceMatch{
+		{Name: "cloud", Prefix: netip.MustParsePrefix("2600:f0f0:2::/48")},
+	})
+	Assert(t).That(filter.Lookup(netip.MustParseAddr("12.1.2.3")) == nil).Equals(true)
+	Assert(t).That(filter.Lookup(netip.Addr{}) == nil).Equals(true)
+}
+func TestCompositeSourcesChangeIndependently(t *testing.T) {
+	filter := NewComposite()
+	filter.now = func() time.Time { return time.Unix(1000, 0) }
+	filter.Set("cloud", "10.0.0.0/8")
+	filter.Set("tor", "10.1.2.3/32", "11.0.0.0/8")
+
+	Assert(t).That(filter.Disable("cloud")).Equals(true)
+	Assert(t).That(filter.Match("10.1.2.3")).Equals([]string{"tor"})
+	assertNotContains(t, filter, "10.9.9.9")
+
+	filter.Set("cloud", "12.0.0.0/8") // a disabled source keeps its state while its rules change
+	assertNotContains(t, filter, "12.1.2.3")
+	Assert(t).That(filter.Enable("cloud")).Equals(true)
+	Assert(t).That(filter.Match("12.1.2.3")).Equals([]string{"cloud"})
+
+	filter.Set("tor", "13.0.0.0/8")
+	assertNotContains(t, filter, "11.1.2.3")
+	assertContains(t, filter, "12.1.2.3", "13.1.2.3")
+
+	Assert(t).That(filter.Sources()).Equals([]SourceInfo{
+		{Name: "cloud", Enabled: true, Rules: 1, Updated: time.Unix(1000, 0)},
+		{Name: "tor", Enabled: true, Rules: 1, Updated: time.Unix(1000, 0)},
+	})
+
+	Assert(t).That(filter.Remove("cloud")).Equals(true)
+	Assert(t).That(filter.Remove("cloud")).Equals(false)
+	Assert(t).That(filter.Enable("cloud")).Equals(false)
+	Assert(t).That(filter.Disable("cloud")).Equals(false)
+	assertNotContains(t, filter, "12.1.2.3")
+	Assert(t).That(filter.Match("13.1.2.3")).Equals([]string{"tor"})
+}
+func TestCompositeMatchesTree(t *testing.T) {
+	rules := corpus()
+	var even, odd []string
+	for i, rule := range rules {
+		if i%2 == 0 {
+			even = append(even, rule)
+		} else {
+			odd = append(odd, rule)
+		}
+	}
+
+	filter := NewComposite()
+	filter.Set("even", even...)
+	filter.Set("odd", odd...)
+
+	assertSameAnswers(t, New(rules...), filter, sampleAddresses(rules)...)
+}
+func TestCompositeLookupsDuringChanges(t *testing.T) {
+	filter := NewComposite()
+	filter.Set("static", "10.0.0.0/8")
+
+	var waiter sync.WaitGroup
+	waiter.Add(1)
+	go func() {
+		defer waiter.Done()
+		for i := 0; i < 100; i++ {
+			filter.Set("changing", "11.0.0.0/8")
+			filter.Disable("changing")
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		assertContains(t, filter, "10.1.2.3")
+	}
+	waiter.Wait()
+}
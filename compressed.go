@@ -0,0 +1,128 @@
+package ipfilter
+
+import (
+	"math/bits"
+	"unsafe"
+)
+
+// compressedTree is a path-compressed (Patricia) trie. Chains of single-child nodes are collapsed into one node
+// holding the full prefix, so each rule costs at most two nodes instead of one node per subnet bit.
+type compressedTree struct {
+	roots [2]*compressedNode
+}
+type compressedNode struct {
+	children   [2]*compressedNode
+	numericIP  uint64
+	subnetBits uint8
+	banned     bool
+}
+
+func NewCompressed(addresses ...string) Filter {
+	this := &compressedTree{}
+
+	for _, item := range addresses {
+		this.add(item)
+	}
+
+	return this
+}
+
+func (this *compressedTree) add(subnetMask string) {
+	family, numericIP, subnetBits, ok := parseSubnetMask(subnetMask)
+	if !ok {
+		return
+	}
+
+	this.roots[family] = insertCompressed(this.roots[family], maskNumericIP(numericIP, subnetBits), subnetBits)
+}
+func insertCompressed(node *compressedNode, numericIP uint64, subnetBits int) *compressedNode {
+	if node == nil {
+		return newCompressedNode(numericIP, subnetBits)
+	}
+
+	nodeBits := int(node.subnetBits)
+	common := commonPrefixBits(node.numericIP, numericIP, min(nodeBits, subnetBits))
+
+	if common == nodeBits {
+		if node.banned {
+			return node // already covered by a shorter (or identical) rule
+		}
+
+		if common == subnetBits {
+			node.banned = true
+			node.children = [2]*compressedNode{} // everything below is now redundant
+			return node
+		}
+
+		nextBit := numericIP << common >> numericBitMask
+		node.children[nextBit] = insertCompressed(node.children[nextBit], numericIP, subnetBits)
+		return node
+	}
+
+	if common == subnetBits {
+		return newCompressedNode(numericIP, subnetBits) // the new rule covers the whole existing subtree
+	}
+
+	parent := &compressedNode{numericIP: maskNumericIP(numericIP, common), subnetBits: uint8(common)}
+	parent.children[node.numericIP<<common>>numericBitMask] = node
+	parent.children[numericIP<<common>>numericBitMask] = newCompressedNode(numericIP, subnetBits)
+	return parent
+}
+func newCompressedNode(numericIP uint64, subnetBits int) *compressedNode {
+	return &compressedNode{numericIP: numericIP, subnetBits: uint8(subnetBits), banned: true}
+}
+
+func (this *compressedTree) Contains(ipAddress string) bool {
+	family, numericIP, ok := parseAddress(ipAddress)
+	return ok && this.contains(family, numericIP)
+}
+func (this *compressedTree) contains(family int, numericIP uint64) bool {
+	_, matched := this.match(family, numericIP)
+	return matched
+}
+func (this *compressedTree) match(family int, numericIP uint64) (int, bool) {
+	for current := this.roots[family]; current != nil; {
+		if maskNumericIP(numericIP, int(current.subnetBits)) != current.numericIP {
+			return 0, false
+		}
+
+		if current.banned {
+			return int(current.subnetBits), true
+		}
+
+		current = current.children[numericIP<<current.subnetBits>>numericBitMask]
+	}
+
+	return 0, false
+}
+
+func (this *compressedTree) Stats() (stats Stats) {
+	for family, root := range this.roots {
+		root.stats(family, &stats)
+	}
+
+	stats.Bytes = stats.Nodes * int(unsafe.Sizeof(compressedNode{}))
+	return stats
+}
+func (this *compressedNode) stats(family int, stats *Stats) {
+	if this == nil {
+		return
+	}
+
+	stats.Nodes++
+	if this.banned {
+		stats.addRule(family)
+	}
+
+	this.children[0].stats(family, stats)
+	this.children[1].stats(family, stats)
+}
+
+func maskNumericIP(numericIP uint64, subnetBits int) uint64 {
+	return numericIP &^ (allNumericBits >> subnetBits)
+}
+func commonPrefixBits(left, right uint64, limit int) int {
+	return min(bits.LeadingZeros64(left^right), limit)
+}
+
+const allNumericBits = ^uint64(0)
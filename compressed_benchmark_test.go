@@ -0,0 +1,42 @@
+package ipfilter
+
+import (
+	"runtime"
+	"testing"
+)
+
+func BenchmarkCompressedTest(b *testing.B) {
+	filter := NewCompressed(ipAddresses...)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		_ = filter.Contains("1.2.3.4")
+	}
+}
+
+func BenchmarkTreeBuild(b *testing.B)       { benchmarkBuild(b, New) }
+func BenchmarkCompressedBuild(b *testing.B) { benchmarkBuild(b, NewCompressed) }
+
+func benchmarkBuild(b *testing.B, build func(...string) Filter) {
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		_ = build(ipAddresses...)
+	}
+
+	b.ReportMetric(float64(retainedBytes(build))/float64(len(ipAddresses)), "bytes/prefix")
+}
+func retainedBytes(build func(...string) Filter) uint64 {
+	var before, after runtime.MemStats
+
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	filter := build(ipAddresses...)
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(filter)
+
+	return after.HeapAlloc - before.HeapAlloc
+}
@@ -0,0 +1,128 @@
+package ipfilter
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestCompressedErrors(t *testing.T) {
+	filter := NewCompressed(
+		"",
+		"10.0.0.0",
+		"random name",
+		"10.0.0.1.1.1/32",
+		"10.0/8",
+		"3|144|0|0/13",
+		"0.0.0.0/10",
+		"2600:f0f0:2::",
+		"2600:h0h0:2::/48",
+		":::::/64",
+	)
+	assertNotContains(t, filter,
+		"",
+		"hello, world!",
+		"10.0.0.1.1.1",
+		"3.144.124.234",
+		"0.0.0.0",
+		"2600:f0f0:2::1",
+		"2600:h0h0:2::",
+		"::::",
+	)
+}
+func TestCompressedSplitsAndCoversExistingPrefixes(t *testing.T) {
+	filter := NewCompressed(
+		"10.1.2.0/24",
+		"10.1.3.0/24",
+		"10.1.0.0/16", // covers both of the above
+		"10.1.4.0/24", // already covered
+		"192.168.1.1/32",
+		"192.168.1.0/32",
+	)
+	assertContains(t, filter, "10.1.2.1", "10.1.3.1", "10.1.255.255", "192.168.1.1", "192.168.1.0")
+	assertNotContains(t, filter, "10.2.0.1", "10.0.255.255", "192.168.1.2")
+}
+func TestCompressedMatchesTree(t *testing.T) {
+	rules := append([]string{
+		"2600:f0f0:2::/48",
+		"2600:1ff6:7400::/40",
+		"2a05:d074:9000::/40",
+		"2605:9cc0:1ff0:600::/56",
+		"2a01:578:0:7301::1/128",
+	}, corpus()...)
+
+	assertSameAnswers(t, New(rules...), NewCompressed(rules...), sampleAddresses(rules)...)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func assertSameAnswers(t *testing.T, expected, actual Filter, values ...string) {
+	t.Helper()
+	for _, value := range values {
+		if expected.Contains(value) != actual.Contains(value) {
+			t.Errorf("%s: expected %t, got %t", value, expected.Contains(value), actual.Contains(value))
+		}
+	}
+}
+
+// sampleAddresses returns the first and last addresses of each rule, their outside neighbors, and random noise.
+// Short runs sample less noise.
+func sampleAddresses(rules []string) (values []string) {
+	random := rand.New(rand.NewSource(42))
+
+	for _, rule := range rules {
+		family, numericIP, subnetBits, ok := parseSubnetMask(rule)
+		if !ok {
+			continue
+		}
+
+		first := maskNumericIP(numericIP, subnetBits)
+		last := first | allNumericBits>>subnetBits
+		if family == ipv4Child {
+			last = last &^ (allNumericBits >> ipv4BitCount)
+		}
+
+		step := uint64(1) << (numericBitCount - familyBitCount(family))
+		for _, value := range []uint64{first - step, first, first + step, last - step, last, last + step} {
+			values = append(values, formatNumericIP(family, value))
+		}
+	}
+
+	noise := 10000
+	if testing.Short() {
+		noise = 50
+	}
+
+	for i := 0; i < noise; i++ {
+		values = append(values, formatNumericIP(ipv4Child, random.Uint64()&^(allNumericBits>>ipv4BitCount)))
+		values = append(values, formatNumericIP(ipv6Child, random.Uint64()))
+	}
+
+	return values
+}
+
+// corpus returns the benchmark rules; short runs (the race tests, with a one second budget) use every 128th rule.
+// The Makefile also runs the full corpus without -short.
+func corpus() (rules []string) {
+	if !testing.Short() {
+		return ipAddresses
+	}
+
+	for i := 0; i < len(ipAddresses); i += 128 {
+		rules = append(rules, ipAddresses[i])
+	}
+	return rules
+}
+func familyBitCount(family int) int {
+	if family == ipv4Child {
+		return ipv4BitCount
+	}
+	return ipv6BitCount
+}
+func formatNumericIP(family int, numericIP uint64) string {
+	if family == ipv4Child {
+		return fmt.Sprintf("%d.%d.%d.%d",
+			byte(numericIP>>56), byte(numericIP>>48), byte(numericIP>>40), byte(numericIP>>32))
+	}
+	return fmt.Sprintf("%x:%x:%x:%x::1", uint16(numericIP>>48), uint16(numericIP>>32), uint16(numericIP>>16), uint16(numericIP))
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/smarty/ip-filter"
+	"github.com/smarty/ip-filter/reload"
+)
+
+// Engine evaluates addresses against the sources of a configuration, merged into one ipfilter.Composite.
+type Engine struct {
+	composite *ipfilter.Composite
+	sources   map[string]engineSource
+	reloaders []scheduledReloader
+	fallback  ipfilter.Action
+	unmap     bool
+}
+type engineSource struct {
+	action   ipfilter.Action
+	priority int
+}
+type scheduledReloader struct {
+	reloader *reload.Reloader
+	interval time.Duration
+}
+
+// Build validates config and loads every source; the first load of each file and feed must succeed (a feed may
+// fall back to its cache). Call Run to keep files and feeds with a reload interval up to date.
+func Build(config Config, options ...option) (*Engine, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var settings configuration
+	Options.apply(options...)(&settings)
+
+	fallback, _ := parseDefault(config.Default)
+	this := &Engine{
+		composite: ipfilter.NewComposite(),
+		sources:   make(map[string]engineSource, len(config.Sources)),
+		fallback:  fallback,
+		unmap:     config.IPv4Mapped != "ipv6",
+	}
+
+	for i, source := range config.Sources {
+		if err := this.add(source, settings); err != nil {
+			return nil, fmt.Errorf("sources[%d]: %w", i, err)
+		}
+	}
+
+	return this, nil
+}
+func (this *Engine) add(source Source, settings configuration) error {
+	action, _ := parseAction(source.Action)
+	this.sources[source.Name] = engineSource{action: action, priority: source.Priority}
+
+	if len(source.Rules) > 0 {
+		this.composite.Set(source.Name, source.Rules...)
+	} else if reloader, err := this.load(source, settings); err != nil {
+		return err
+	} else if interval, _ := source.reloadInterval(); interval > 0 {
+		this.reloaders = append(this.reloaders, scheduledReloader{reloader: reloader, interval: interval})
+	}
+
+	if source.Disabled {
+		this.composite.Disable(source.Name)
+	}
+
+	return nil
+}
+func (this *Engine) load(source Source, settings configuration) (*reload.Reloader, error) {
+	parser := reload.Options.Parser(source.parser())
+	logger := reload.Options.Logger(settings.logger)
+	// the composite holds the rules; the reloader only needs to hand them over
+	constructor := reload.Options.Constructor(func(...string) ipfilter.Filter { return ipfilter.NewCompressed() })
+	callback := reload.Options.Callback(func(event reload.Event) {
+		if event.Err == nil {
+			this.composite.Set(source.Name, event.Rules...)
+		}
+		settings.callback(source.Name, event)
+	})
+
+	if len(source.Files) > 0 {
+		return reload.NewFiles(source.Files, parser, logger, constructor, callback)
+	}
+
+	return reload.NewFeed(source.URL, parser, logger, constructor, callback,
+		reload.Options.Cache(source.Cache), reload.Options.MaximumSize(source.MaximumSize))
+}
+func (this Source) parser() func(io.Reader) ([]string, error) {
+	if this.Format == "json" {
+		return reload.ParseJSON(this.Fields...)
+	}
+
+	return reload.ParseLines
+}
+
+// Run reloads files and feeds that have a reload interval until ctx is cancelled.
+func (this *Engine) Run(ctx context.Context) {
+	var waiter sync.WaitGroup
+	defer waiter.Wait()
+
+	for _, item := range this.reloaders {
+		waiter.Add(1)
+		go func(item scheduledReloader) {
+			defer waiter.Done()
+			item.reloader.Run(ctx, item.interval)
+		}(item)
+	}
+}
+
+// Reload checks every file and feed once, regardless of its interval, and returns the errors of those that failed.
+func (this *Engine) Reload(ctx context.Context) (err error) {
+	for _, item := range this.reloaders {
+		err = errors.Join(err, item.reloader.Reload(ctx))
+	}
+
+	return err
+}
+
+// Verdict is the outcome of Engine.Evaluate.
+type Verdict struct {
+	Action  ipfilter.Action
+	Source  string       // the source that decided; empty when the default action applies
+	Prefix  netip.Prefix // the most specific rule of that source containing the address
+	Matched []string     // every enabled source containing the address, in configuration order
+}
+
+// Evaluate finds the enabled sources containing the address and decides like ipfilter.Policy: the source with the
+// highest priority wins, ties go to the source with the longest (most specific) rule containing the address, and
+// then to the source listed first.
+func (this *Engine) Evaluate(address netip.Addr) Verdict {
+	if this.unmap {
+		address = address.Unmap()
+	}
+
+	verdict := Verdict{Action: this.fallback}
+
+	decided := engineSource{}
+	for _, match := range this.composite.Lookup(address) {
+		verdict.Matched = append(verdict.Matched, match.Name)
+		if source := this.sources[match.Name]; len(verdict.Source) == 0 || source.decides(match, decided, verdict.Prefix) {
+			verdict.Source, verdict.Prefix, verdict.Action, decided = match.Name, match.Prefix, source.action, source
+		}
+	}
+
+	return verdict
+}
+
+// decides reports whether a match of this source overrides the decision so far, made by a source listed earlier.
+func (this engineSource) decides(match ipfilter.SourceMatch, decided engineSource, decidedPrefix netip.Prefix) bool {
+	if this.priority != decided.priority {
+		return this.priority > decided.priority
+	}
+
+	return match.Prefix.Bits() > decidedPrefix.Bits()
+}
+
+// Contains reports whether the address is denied, so an Engine can be used wherever a Filter is expected.
+func (this *Engine) Contains(ipAddress string) bool {
+	address, err := netip.ParseAddr(ipAddress)
+	return err == nil && this.ContainsAddr(address)
+}
+func (this *Engine) ContainsAddr(address netip.Addr) bool {
+	return this.Evaluate(address).Action == ipfilter.Deny
+}
+
+// Composite returns the merged sources, for enabling and disabling them at runtime.
+func (this *Engine) Composite() *ipfilter.Composite { return this.composite }
+
+func (this *Engine) Stats() ipfilter.Stats { return this.composite.Stats() }
+
+type configuration struct {
+	logger   *slog.Logger
+	callback func(source string, event reload.Event)
+}
+
+var Options singleton
+
+type singleton struct{}
+type option func(*configuration)
+
+// Logger is passed to the reloaders of files and feeds (default: no logging).
+func (singleton) Logger(value *slog.Logger) option {
+	return func(this *configuration) { this.logger = value }
+}
+
+// Callback receives the outcome of every load of a file or feed, with the name of its source (default: none).
+func (singleton) Callback(value func(source string, event reload.Event)) option {
+	return func(this *configuration) { this.callback = value }
+}
+
+func (singleton) apply(options ...option) option {
+	return func(this *configuration) {
+		for _, item := range Options.defaults(options...) {
+			item(this)
+		}
+	}
+}
+func (singleton) defaults(options ...option) []option {
+	return append([]option{
+		Options.Callback(func(string, reload.Event) {}),
+	}, options...)
+}
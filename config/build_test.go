@@ -0,0 +1,126 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smarty/ip-filter"
+	"github.com/smarty/ip-filter/reload"
+)
+
+func TestBuildEvaluatesSourcesByPriorityThenOrder(t *testing.T) {
+	engine, err := Build(Config{Sources: []Source{
+		{Name: "cloud", Action: "log", Rules: []string{"10.0.0.0/8"}},
+		{Name: "tor", Rules: []string{"10.1.0.0/16", "11.0.0.0/8"}},
+		{Name: "office", Action: "allow", Priority: 10, Rules: []string{"10.1.2.0/24"}},
+		{Name: "retired", Rules: []string{"12.0.0.0/8"}, Disabled: true},
+	}})
+	Assert(t).That(err).Equals(nil)
+
+	Assert(t).That(evaluate(engine, "10.1.2.3")).Equals(Verdict{
+		Action: ipfilter.Allow, Source: "office", Prefix: netip.MustParsePrefix("10.1.2.0/24"), Matched: []string{"cloud", "tor", "office"}})
+	Assert(t).That(evaluate(engine, "10.1.9.9")).Equals(Verdict{
+		Action: ipfilter.Deny, Source: "tor", Prefix: netip.MustParsePrefix("10.1.0.0/16"), Matched: []string{"cloud", "tor"}}) // same priority: longest prefix
+	Assert(t).That(evaluate(engine, "11.1.2.3")).Equals(Verdict{
+		Action: ipfilter.Deny, Source: "tor", Prefix: netip.MustParsePrefix("11.0.0.0/8"), Matched: []string{"tor"}})
+	Assert(t).That(evaluate(engine, "12.1.2.3")).Equals(Verdict{Action: ipfilter.Allow})
+	Assert(t).That(engine.Contains("10.1.9.9")).Equals(true)
+
+	Assert(t).That(engine.Contains("11.1.2.3")).Equals(true)
+	Assert(t).That(engine.Contains("not an address")).Equals(false)
+	Assert(t).That(engine.Stats().Rules()).Equals(4)
+
+	engine.Composite().Enable("retired")
+	Assert(t).That(engine.Contains("12.1.2.3")).Equals(true)
+}
+func TestBuildBreaksTiesLikePolicy(t *testing.T) {
+	engine, _ := Build(Config{Sources: []Source{
+		{Name: "tor", Rules: []string{"10.0.0.0/8"}},
+		{Name: "office", Action: "allow", Rules: []string{"10.1.2.0/24"}},
+		{Name: "cloud", Action: "log", Rules: []string{"10.1.2.0/24", "11.0.0.0/8"}},
+		{Name: "scanners", Rules: []string{"11.0.0.0/8"}},
+	}})
+	policy, _ := ipfilter.ParsePolicy(strings.NewReader(`
+deny  10.0.0.0/8  name=tor
+allow 10.1.2.0/24 name=office
+log   10.1.2.0/24 name=cloud
+log   11.0.0.0/8  name=cloud
+deny  11.0.0.0/8  name=scanners
+`))
+
+	for _, address := range []string{"10.1.2.3", "10.9.9.9", "11.1.2.3", "12.1.2.3"} {
+		verdict, decision := evaluate(engine, address), policy.Evaluate(netip.MustParseAddr(address))
+		Assert(t).That(verdict.Action).Equals(decision.Action)
+		if decision.Rule != nil {
+			Assert(t).That(verdict.Source).Equals(decision.Rule.Name)
+		}
+	}
+	Assert(t).That(evaluate(engine, "10.1.2.3").Source).Equals("office") // longer than tor, listed before cloud
+}
+func TestBuildHandlesIPv4MappedAddresses(t *testing.T) {
+	sources := []Source{{Name: "tor", Rules: []string{"10.0.0.0/8"}}}
+
+	engine, _ := Build(Config{Default: "deny", Sources: sources})
+	Assert(t).That(evaluate(engine, "::ffff:10.1.2.3").Source).Equals("tor")
+
+	engine, _ = Build(Config{Default: "deny", IPv4Mapped: "ipv6", Sources: sources})
+	Assert(t).That(evaluate(engine, "::ffff:10.1.2.3")).Equals(Verdict{Action: ipfilter.Deny})
+}
+func TestBuildLoadsAndReloadsFilesAndFeeds(t *testing.T) {
+	directory := t.TempDir()
+	path := filepath.Join(directory, "blocklist.txt")
+	_ = os.WriteFile(path, []byte("10.0.0.0/8\n"), 0o644)
+
+	feed := "11.0.0.0/8"
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		_, _ = response.Write([]byte(`{"prefixes": [{"ip_prefix": "` + feed + `"}]}`))
+	}))
+	defer server.Close()
+
+	var loaded []string
+	engine, err := Build(Config{Sources: []Source{
+		{Name: "file", Files: []string{path}, Reload: "1h"},
+		{Name: "feed", URL: server.URL, Format: "json", Fields: []string{"ip_prefix"}, Reload: "1h"},
+		{Name: "once", URL: server.URL, Format: "json", Fields: []string{"ip_prefix"}},
+	}}, Options.Callback(func(source string, event reload.Event) { loaded = append(loaded, source) }))
+
+	Assert(t).That(err).Equals(nil)
+	Assert(t).That(loaded).Equals([]string{"file", "feed", "once"})
+	Assert(t).That(evaluate(engine, "11.1.2.3").Matched).Equals([]string{"feed", "once"})
+
+	feed = "12.0.0.0/8"
+	_ = os.WriteFile(path, []byte("13.0.0.0/8\n"), 0o644)
+	_ = os.Chtimes(path, time.Unix(2, 0), time.Unix(2, 0))
+	Assert(t).That(engine.Reload(context.Background())).Equals(nil)
+
+	Assert(t).That(evaluate(engine, "13.1.2.3").Matched).Equals([]string{"file"})
+	Assert(t).That(evaluate(engine, "12.1.2.3").Matched).Equals([]string{"feed"})
+	Assert(t).That(evaluate(engine, "11.1.2.3").Matched).Equals([]string{"once"})
+	Assert(t).That(evaluate(engine, "10.1.2.3").Matched == nil).Equals(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	engine.Run(ctx) // returns once every reloader has stopped
+}
+func TestBuildFailures(t *testing.T) {
+	_, err := Build(Config{})
+	Assert(t).That(err.Error()).Equals("sources: at least one source is required")
+
+	engine, err := Build(Config{Sources: []Source{
+		{Name: "inline", Rules: []string{"10.0.0.0/8"}},
+		{Name: "missing", Files: []string{filepath.Join(t.TempDir(), "missing.txt")}},
+	}})
+	Assert(t).That(engine == nil).Equals(true)
+	Assert(t).That(strings.HasPrefix(err.Error(), "sources[1]: stat ")).Equals(true)
+}
+
+func evaluate(engine *Engine, address string) Verdict {
+	return engine.Evaluate(netip.MustParseAddr(address))
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config describes a filter made of named sources, matching schema.json. Fields use JSON tags; other formats
+// (YAML, TOML) can fill the same struct with their own decoders and then call Validate and Build.
+type Config struct {
+	Schema     string   `json:"$schema,omitempty"`     // lets editors find schema.json; ignored
+	Default    string   `json:"default,omitempty"`     // for unmatched addresses: "allow" (default), "deny" or "log"
+	IPv4Mapped string   `json:"ipv4_mapped,omitempty"` // "unmap" (default) checks ::ffff:a.b.c.d as a.b.c.d, "ipv6" not
+	Sources    []Source `json:"sources"`
+}
+
+// Source is one rule set. Exactly one of Rules, Files and URL is set.
+type Source struct {
+	Name     string `json:"name"`
+	Action   string `json:"action,omitempty"`   // "deny" (default), "allow" or "log"
+	Priority int    `json:"priority,omitempty"` // the highest priority among matching sources decides, then the longest prefix, then config order
+	Disabled bool   `json:"disabled,omitempty"`
+
+	Rules []string `json:"rules,omitempty"`
+	Files []string `json:"files,omitempty"`
+	URL   string   `json:"url,omitempty"`
+
+	Format      string   `json:"format,omitempty"` // "lines" (default) or "json", for files and feeds
+	Fields      []string `json:"fields,omitempty"` // the JSON fields holding rules, for the "json" format
+	Cache       string   `json:"cache,omitempty"`  // where a feed keeps its last good copy
+	MaximumSize int64    `json:"maximum_size,omitempty"`
+	Reload      string   `json:"reload,omitempty"` // how often files and feeds are checked, e.g. "30s"; unset loads once
+}
+
+// Load reads and validates the JSON configuration file at path.
+func Load(path string) (Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config, err := Parse(bytes.NewReader(content))
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// Parse decodes and validates a JSON configuration. Unknown fields are rejected, and syntax and type errors report
+// the line on which they occur.
+func Parse(reader io.Reader) (config Config, err error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return Config{}, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&config); err != nil {
+		return Config{}, describeJSONError(content, err)
+	}
+
+	return config, config.Validate()
+}
+func describeJSONError(content []byte, err error) error {
+	var syntax *json.SyntaxError
+	var mismatch *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntax):
+		return fmt.Errorf("line %d: %w", lineOf(content, syntax.Offset), err)
+	case errors.As(err, &mismatch):
+		return fmt.Errorf("line %d: %s: expected %s, found %s",
+			lineOf(content, mismatch.Offset), fieldPath(mismatch.Field), mismatch.Type, mismatch.Value)
+	default:
+		return err
+	}
+}
+
+// fieldPath writes "sources.0.priority" the way Validate does: "sources[0].priority".
+func fieldPath(field string) string {
+	var path strings.Builder
+	for i, segment := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(segment); err == nil {
+			path.WriteString("[" + segment + "]")
+			continue
+		}
+		if i > 0 {
+			path.WriteString(".")
+		}
+		path.WriteString(segment)
+	}
+	return path.String()
+}
+func lineOf(content []byte, offset int64) int {
+	return bytes.Count(content[:min(offset, int64(len(content)))], []byte("\n")) + 1
+}
+
+// Schema is the JSON Schema of the configuration, for editor support.
+//
+//go:embed schema.json
+var Schema []byte
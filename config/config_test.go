@@ -0,0 +1,113 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	config, err := Parse(strings.NewReader(`{
+		"default": "deny",
+		"sources": [
+			{"name": "office", "action": "allow", "rules": ["192.0.2.0/24"]},
+			{"name": "aws", "action": "log", "url": "https://example.com/ranges.json", "format": "json",
+				"fields": ["ip_prefix"], "reload": "1h", "cache": "/tmp/aws.json"}
+		]
+	}`))
+
+	Assert(t).That(err).Equals(nil)
+	Assert(t).That(config).Equals(Config{
+		Default: "deny",
+		Sources: []Source{
+			{Name: "office", Action: "allow", Rules: []string{"192.0.2.0/24"}},
+			{Name: "aws", Action: "log", URL: "https://example.com/ranges.json", Format: "json",
+				Fields: []string{"ip_prefix"}, Reload: "1h", Cache: "/tmp/aws.json"},
+		},
+	})
+}
+func TestParseReportsLines(t *testing.T) {
+	for text, expected := range map[string]string{
+		"{\n\"sources\": [\n{\"name\": \"a\",,}]}":           "line 3: invalid character ',' looking for beginning of object key string",
+		"{\n\"sources\": [],\n\"color\": \"red\"}":           `json: unknown field "color"`,
+		`{"sources": [{"name": "a", "rules": ["1.2.3.4"]}]}`: `sources[0].rules[0]: missing subnet bits (expected address/bits): "1.2.3.4"`,
+	} {
+		_, err := Parse(strings.NewReader(text))
+		Assert(t).That(err.Error()).Equals(expected)
+	}
+
+	_, err := Parse(strings.NewReader("{\n\"sources\": [\n{\"priority\": \"high\"}]}"))
+	Assert(t).That(strings.HasPrefix(err.Error(), "line 3: sources")).Equals(true) // older Go versions omit the index
+	Assert(t).That(strings.HasSuffix(err.Error(), ".priority: expected int, found string")).Equals(true)
+	Assert(t).That(fieldPath("sources.0.priority")).Equals("sources[0].priority")
+}
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	_ = os.WriteFile(path, []byte(`{"sources": [{"name": "a", "rules": ["10.0.0.0/8"]}]}`), 0o644)
+
+	config, err := Load(path)
+	Assert(t).That(err).Equals(nil)
+	Assert(t).That(config.Sources[0].Rules).Equals([]string{"10.0.0.0/8"})
+
+	_ = os.WriteFile(path, []byte(`{"sources": []}`), 0o644)
+	_, err = Load(path)
+	Assert(t).That(err.Error()).Equals(path + ": sources: at least one source is required")
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.json"))
+	Assert(t).That(os.IsNotExist(err)).Equals(true)
+}
+func TestExampleIsValid(t *testing.T) {
+	config, err := Load("example.json")
+	Assert(t).That(err).Equals(nil)
+	Assert(t).That(len(config.Sources)).Equals(3)
+}
+func TestSchemaDescribesEveryField(t *testing.T) {
+	var schema struct {
+		Properties map[string]any
+		Defs       struct {
+			Source struct{ Properties map[string]any }
+		} `json:"$defs"`
+	}
+	Assert(t).That(json.Unmarshal(Schema, &schema)).Equals(nil)
+
+	Assert(t).That(sortedKeys(schema.Properties)).Equals(jsonFields(reflect.TypeOf(Config{})))
+	Assert(t).That(sortedKeys(schema.Defs.Source.Properties)).Equals(jsonFields(reflect.TypeOf(Source{})))
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func sortedKeys(values map[string]any) (keys []string) {
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+func jsonFields(structure reflect.Type) (fields []string) {
+	for i := 0; i < structure.NumField(); i++ {
+		name, _, _ := strings.Cut(structure.Field(i).Tag.Get("json"), ",")
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+type That struct{ t *testing.T }
+type Assertion struct {
+	*testing.T
+	actual interface{}
+}
+
+func Assert(t *testing.T) *That                       { return &That{t: t} }
+func (this *That) That(actual interface{}) *Assertion { return &Assertion{T: this.t, actual: actual} }
+
+func (this *Assertion) Equals(expected interface{}) {
+	this.Helper()
+	if !reflect.DeepEqual(this.actual, expected) {
+		this.Errorf("\nExpected: %#v\nActual:   %#v", expected, this.actual)
+	}
+}
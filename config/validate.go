@@ -0,0 +1,136 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/smarty/ip-filter"
+)
+
+// Validate checks the whole configuration and reports every problem, each prefixed with its path, such as
+// `sources[2].rules[5]: invalid subnet bits: "10.0.0.0/33"`.
+func (this Config) Validate() error {
+	var problems []error
+	report := func(path string, err error) { problems = append(problems, fmt.Errorf("%s: %w", path, err)) }
+
+	if _, err := parseDefault(this.Default); err != nil {
+		report("default", err)
+	}
+	if this.IPv4Mapped != "" && this.IPv4Mapped != "unmap" && this.IPv4Mapped != "ipv6" {
+		report("ipv4_mapped", fmt.Errorf("%w: %q (expected \"unmap\" or \"ipv6\")", ErrInvalidValue, this.IPv4Mapped))
+	}
+	if len(this.Sources) == 0 {
+		report("sources", ErrNoSources)
+	}
+
+	names := make(map[string]int, len(this.Sources))
+	for i, source := range this.Sources {
+		path := fmt.Sprintf("sources[%d]", i)
+
+		if len(source.Name) == 0 {
+			report(path+".name", ErrMissingName)
+		} else if first, found := names[source.Name]; found {
+			report(path+".name", fmt.Errorf("%w: %q (also sources[%d])", ErrDuplicateName, source.Name, first))
+		} else {
+			names[source.Name] = i
+		}
+
+		source.validate(path, report)
+	}
+
+	return errors.Join(problems...)
+}
+func (this Source) validate(path string, report func(string, error)) {
+	if _, err := parseAction(this.Action); err != nil {
+		report(path+".action", err)
+	}
+
+	kinds := 0
+	for _, present := range []bool{len(this.Rules) > 0, len(this.Files) > 0, len(this.URL) > 0} {
+		if present {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		report(path, ErrSourceKind)
+	}
+
+	for i, rule := range this.Rules {
+		if _, err := ipfilter.ParseRule(rule); err != nil {
+			report(fmt.Sprintf("%s.rules[%d]", path, i), fmt.Errorf("%w: %q", err, rule))
+		}
+	}
+	for i, file := range this.Files {
+		if len(file) == 0 {
+			report(fmt.Sprintf("%s.files[%d]", path, i), ErrInvalidValue)
+		}
+	}
+	if len(this.URL) > 0 {
+		if parsed, err := url.Parse(this.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			report(path+".url", fmt.Errorf("%w: %q (expected an http or https URL)", ErrInvalidValue, this.URL))
+		}
+	}
+
+	switch this.Format {
+	case "", "lines":
+		if len(this.Fields) > 0 {
+			report(path+".fields", fmt.Errorf("%w: only used by the \"json\" format", ErrInvalidValue))
+		}
+	case "json":
+		if len(this.Fields) == 0 {
+			report(path+".fields", fmt.Errorf("%w: the \"json\" format needs at least one field", ErrInvalidValue))
+		}
+	default:
+		report(path+".format", fmt.Errorf("%w: %q (expected \"lines\" or \"json\")", ErrInvalidValue, this.Format))
+	}
+
+	if len(this.Rules) > 0 && (len(this.Format) > 0 || len(this.Reload) > 0) {
+		report(path, fmt.Errorf("%w: format and reload only apply to files and feeds", ErrInvalidValue))
+	}
+	if len(this.URL) == 0 && (len(this.Cache) > 0 || this.MaximumSize != 0) {
+		report(path, fmt.Errorf("%w: cache and maximum_size only apply to feeds", ErrInvalidValue))
+	}
+	if this.MaximumSize < 0 {
+		report(path+".maximum_size", fmt.Errorf("%w: %d", ErrInvalidValue, this.MaximumSize))
+	}
+	if _, err := this.reloadInterval(); err != nil {
+		report(path+".reload", err)
+	}
+}
+
+func (this Source) reloadInterval() (time.Duration, error) {
+	if len(this.Reload) == 0 {
+		return 0, nil
+	}
+
+	interval, err := time.ParseDuration(this.Reload)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("%w: %q (expected a positive duration such as \"30s\")", ErrInvalidValue, this.Reload)
+	}
+
+	return interval, nil
+}
+func parseAction(value string) (ipfilter.Action, error) {
+	if len(value) == 0 {
+		return ipfilter.Deny, nil
+	}
+
+	return ipfilter.ParseAction(value)
+}
+func parseDefault(value string) (ipfilter.Action, error) {
+	if len(value) == 0 {
+		return ipfilter.Allow, nil
+	}
+
+	return ipfilter.ParseAction(value)
+}
+
+var (
+	ErrNoSources     = errors.New("at least one source is required")
+	ErrMissingName   = errors.New("missing name")
+	ErrDuplicateName = errors.New("duplicate name")
+	ErrSourceKind    = errors.New("exactly one of rules, files or url is required")
+	ErrInvalidValue  = errors.New("invalid value")
+)
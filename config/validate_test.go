@@ -0,0 +1,47 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateReportsEveryProblemWithItsPath(t *testing.T) {
+	err := Config{
+		Default:    "block",
+		IPv4Mapped: "ipv4",
+		Sources: []Source{
+			{Name: "a", Action: "drop", Rules: []string{"10.0.0.0/8", "10.0.0.0/33"}, Reload: "1m"},
+			{Name: "a", Files: []string{""}, Format: "csv", Cache: "/tmp/a", Reload: "soon"},
+			{URL: "ftp://example.com", Format: "json", MaximumSize: -1},
+			{Name: "b", Files: []string{"rules.txt"}, Fields: []string{"ip_prefix"}},
+			{Name: "c", Rules: []string{"10.0.0.0/8"}, URL: "https://example.com"},
+			{Name: "d"},
+		},
+	}.Validate()
+
+	Assert(t).That(strings.Split(err.Error(), "\n")).Equals([]string{
+		`default: unknown policy action: "block"`,
+		`ipv4_mapped: invalid value: "ipv4" (expected "unmap" or "ipv6")`,
+		`sources[0].action: unknown policy action: "drop"`,
+		`sources[0].rules[1]: invalid subnet bits: "10.0.0.0/33"`,
+		`sources[0]: invalid value: format and reload only apply to files and feeds`,
+		`sources[1].name: duplicate name: "a" (also sources[0])`,
+		`sources[1].files[0]: invalid value`,
+		`sources[1].format: invalid value: "csv" (expected "lines" or "json")`,
+		`sources[1]: invalid value: cache and maximum_size only apply to feeds`,
+		`sources[1].reload: invalid value: "soon" (expected a positive duration such as "30s")`,
+		`sources[2].name: missing name`,
+		`sources[2].url: invalid value: "ftp://example.com" (expected an http or https URL)`,
+		`sources[2].fields: invalid value: the "json" format needs at least one field`,
+		`sources[2].maximum_size: invalid value: -1`,
+		`sources[3].fields: invalid value: only used by the "json" format`,
+		`sources[4]: exactly one of rules, files or url is required`,
+		`sources[5]: exactly one of rules, files or url is required`,
+	})
+	Assert(t).That(errors.Is(err, ErrDuplicateName)).Equals(true)
+}
+func TestValidateAcceptsMinimalConfig(t *testing.T) {
+	Assert(t).That(Config{Sources: []Source{{Name: "a", Rules: []string{"10.0.0.0/8"}}}}.Validate()).Equals(nil)
+	Assert(t).That(Config{}.Validate().Error()).Equals("sources: at least one source is required")
+}
@@ -0,0 +1,112 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"sort"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// CountingFilter answers like the other filters and additionally counts, for every rule, how often it was the
+// deciding (shortest) match and when it last matched. Rules that never fire, including rules nested inside other
+// rules, keep a zero count and are candidates for pruning. Counters are atomic; lookups take no locks.
+type CountingFilter struct {
+	rules valueTrie[ruleCounter]
+	count int
+	now   func() time.Time
+}
+type ruleCounter struct {
+	rule    string
+	hits    atomic.Uint64
+	lastHit atomic.Int64 // unix nanoseconds
+}
+
+func NewCounting(addresses ...string) *CountingFilter {
+	this := &CountingFilter{now: time.Now}
+
+	for _, item := range addresses {
+		family, numericIP, subnetBits, ok := parseSubnetMask(item)
+		if !ok {
+			continue
+		}
+
+		if counter, created := this.rules.insert(family, numericIP, subnetBits); created {
+			counter.rule = item
+			this.count++
+		}
+	}
+
+	return this
+}
+
+func (this *CountingFilter) Contains(ipAddress string) bool {
+	family, numericIP, ok := parseAddress(ipAddress)
+	return ok && this.contains(family, numericIP)
+}
+func (this *CountingFilter) contains(family int, numericIP uint64) (matched bool) {
+	this.rules.walk(family, numericIP, func(_ int, counter *ruleCounter) bool {
+		counter.hits.Add(1)
+		counter.lastHit.Store(this.now().UnixNano())
+		matched = true
+		return false
+	})
+	return matched
+}
+
+// match reports the deciding rule without counting a hit.
+func (this *CountingFilter) match(family int, numericIP uint64) (subnetBits int, matched bool) {
+	this.rules.walk(family, numericIP, func(bits int, _ *ruleCounter) bool {
+		subnetBits, matched = bits, true
+		return false
+	})
+	return subnetBits, matched
+}
+
+func (this *CountingFilter) Stats() Stats {
+	stats := this.rules.stats(int(unsafe.Sizeof(valueNode[ruleCounter]{})))
+	this.rules.each(func(_ int, _ uint64, _ int, counter *ruleCounter) { stats.Bytes += len(counter.rule) })
+	return stats
+}
+
+// RuleStats describes the counters of a single rule.
+type RuleStats struct {
+	Rule    string       // as it was given to NewCounting
+	Prefix  netip.Prefix // the masked prefix the rule was parsed into
+	Hits    uint64
+	LastHit time.Time // zero when the rule never matched
+}
+
+// Snapshot returns the counters of every rule in address order.
+func (this *CountingFilter) Snapshot() []RuleStats {
+	stats := make([]RuleStats, 0, this.count)
+
+	this.rules.each(func(family int, numericIP uint64, subnetBits int, counter *ruleCounter) {
+		item := RuleStats{Rule: counter.rule, Prefix: formatPrefix(family, numericIP, subnetBits), Hits: counter.hits.Load()}
+		if lastHit := counter.lastHit.Load(); lastHit != 0 {
+			item.LastHit = time.Unix(0, lastHit)
+		}
+		stats = append(stats, item)
+	})
+
+	return stats
+}
+
+// Top returns up to count rules with the most hits, most hits first.
+func (this *CountingFilter) Top(count int) []RuleStats {
+	stats := this.Snapshot()
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Hits > stats[j].Hits })
+
+	if count < len(stats) {
+		stats = stats[:max(count, 0)]
+	}
+	return stats
+}
+
+// Reset sets every counter back to zero.
+func (this *CountingFilter) Reset() {
+	this.rules.each(func(_ int, _ uint64, _ int, counter *ruleCounter) {
+		counter.hits.Store(0)
+		counter.lastHit.Store(0)
+	})
+}
@@ -0,0 +1,28 @@
+package ipfilter
+
+import "testing"
+
+func BenchmarkCountersOff(b *testing.B) { benchmarkMatches(b, NewCompressed(ipAddresses...)) }
+func BenchmarkCountersOn(b *testing.B)  { benchmarkMatches(b, NewCounting(ipAddresses...)) }
+
+func BenchmarkCountersOffParallel(b *testing.B) {
+	benchmarkParallelMatches(b, NewCompressed(ipAddresses...))
+}
+func BenchmarkCountersOnParallel(b *testing.B) {
+	benchmarkParallelMatches(b, NewCounting(ipAddresses...))
+}
+
+func benchmarkMatches(b *testing.B, filter Filter) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		_ = filter.Contains("52.93.126.244") // matches, so the counter is updated
+	}
+}
+func benchmarkParallelMatches(b *testing.B, filter Filter) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = filter.Contains("52.93.126.244")
+		}
+	})
+}
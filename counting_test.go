@@ -0,0 +1,58 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestCountingCountsDecidingRule(t *testing.T) {
+	filter := NewCounting("10.0.0.0/8", "10.1.0.0/16", "2600:f0f0:2::/48", "random name", "10.0.0.0/8")
+	filter.now = func() time.Time { return time.Unix(1000, 0) }
+
+	assertContains(t, filter, "10.1.2.3", "10.2.3.4", "2600:f0f0:2::1")
+	assertNotContains(t, filter, "11.0.0.0", "2600:f0f0:3::1")
+
+	Assert(t).That(filter.Snapshot()).Equals([]RuleStats{
+		{Rule: "10.0.0.0/8", Prefix: netip.MustParsePrefix("10.0.0.0/8"), Hits: 2, LastHit: time.Unix(1000, 0)},
+		{Rule: "10.1.0.0/16", Prefix: netip.MustParsePrefix("10.1.0.0/16")}, // shadowed by 10.0.0.0/8
+		{Rule: "2600:f0f0:2::/48", Prefix: netip.MustParsePrefix("2600:f0f0:2::/48"), Hits: 1, LastHit: time.Unix(1000, 0)},
+	})
+}
+func TestCountingTopAndReset(t *testing.T) {
+	filter := NewCounting("1.0.0.0/8", "2.0.0.0/8", "3.0.0.0/8")
+	assertContains(t, filter, "2.0.0.1", "2.0.0.2", "3.0.0.1")
+
+	top := filter.Top(2)
+	Assert(t).That([]string{top[0].Rule, top[1].Rule}).Equals([]string{"2.0.0.0/8", "3.0.0.0/8"})
+	Assert(t).That([]uint64{top[0].Hits, top[1].Hits}).Equals([]uint64{2, 1})
+	Assert(t).That(len(filter.Top(10))).Equals(3)
+	Assert(t).That(len(filter.Top(-1))).Equals(0)
+
+	filter.Reset()
+	for _, item := range filter.Snapshot() {
+		Assert(t).That(item.Hits).Equals(uint64(0))
+		Assert(t).That(item.LastHit.IsZero()).Equals(true)
+	}
+}
+func TestCountingMatchesTree(t *testing.T) {
+	rules := append([]string{"2600:f0f0:2::/48", "2a01:578:0:7301::1/128", "3.0.0.0/9"}, corpus()...)
+	assertSameAnswers(t, New(rules...), NewCounting(rules...), sampleAddresses(rules)...)
+}
+func TestValueTrieVisitsNestedPrefixesShortestFirst(t *testing.T) {
+	var trie valueTrie[string]
+	for _, rule := range []string{"10.1.2.0/24", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.3/32", "10.128.0.0/9"} {
+		family, numericIP, subnetBits, _ := parseSubnetMask(rule)
+		value, _ := trie.insert(family, numericIP, subnetBits)
+		*value = rule
+	}
+
+	var visited []string
+	family, numericIP, _ := parseAddress("10.1.2.3")
+	trie.walk(family, numericIP, func(_ int, value *string) bool { visited = append(visited, *value); return true })
+	Assert(t).That(visited).Equals([]string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.2.3/32"})
+
+	visited = nil
+	trie.each(func(_ int, _ uint64, _ int, value *string) { visited = append(visited, *value) })
+	Assert(t).That(visited).Equals([]string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.2.3/32", "10.128.0.0/9"})
+}
@@ -0,0 +1,232 @@
+package ipfilter
+
+import (
+	"context"
+	"math"
+	"net/netip"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// ExpiringFilter is a mutable filter whose rules may carry an expiration time, e.g. for fail2ban-style temporary
+// bans. Lookups ignore expired rules; Sweep (or RunSweeper in the background) removes them from the trie.
+type ExpiringFilter struct {
+	lock  sync.RWMutex
+	now   func() time.Time
+	roots [2]*expiringNode
+	count int
+}
+type expiringNode struct {
+	children [2]*expiringNode
+	expires  int64 // unix nanoseconds; 0 when no rule ends at this node
+}
+
+// NewExpiring creates an empty filter; now is used as the clock and defaults to time.Now when nil.
+func NewExpiring(now func() time.Time) *ExpiringFilter {
+	if now == nil {
+		now = time.Now
+	}
+
+	return &ExpiringFilter{now: now, roots: [2]*expiringNode{{}, {}}}
+}
+
+// Add inserts or replaces a rule; a zero expiration never expires. It reports whether the rule could be parsed.
+func (this *ExpiringFilter) Add(subnetMask string, expiration time.Time) bool {
+	family, numericIP, subnetBits, ok := parseSubnetMask(subnetMask)
+	return ok && this.add(family, numericIP, subnetBits, expiration, false)
+}
+
+// AddPrefix behaves like Add.
+func (this *ExpiringFilter) AddPrefix(prefix netip.Prefix, expiration time.Time) bool {
+	family, numericIP, subnetBits, ok := parsePrefix(prefix)
+	return ok && this.add(family, numericIP, subnetBits, expiration, false)
+}
+
+// Extend is Add that never shortens a rule: an existing rule for the same prefix that ends later (or never) keeps its
+// expiration. Use it when several writers share the filter, so an automatic ban does not cut a manual one short.
+func (this *ExpiringFilter) Extend(subnetMask string, expiration time.Time) bool {
+	family, numericIP, subnetBits, ok := parseSubnetMask(subnetMask)
+	return ok && this.add(family, numericIP, subnetBits, expiration, true)
+}
+
+// ExtendPrefix behaves like Extend.
+func (this *ExpiringFilter) ExtendPrefix(prefix netip.Prefix, expiration time.Time) bool {
+	family, numericIP, subnetBits, ok := parsePrefix(prefix)
+	return ok && this.add(family, numericIP, subnetBits, expiration, true)
+}
+func (this *ExpiringFilter) add(family int, numericIP uint64, subnetBits int, expiration time.Time, extend bool) bool {
+	expires := int64(math.MaxInt64)
+	if !expiration.IsZero() {
+		expires = expiration.UnixNano()
+	}
+	if expires == 0 {
+		expires = -1 // the Unix epoch: already expired, but still a rule (0 means no rule)
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	current := this.roots[family]
+	for i := 0; i < subnetBits; i++ {
+		nextBit := numericIP << i >> numericBitMask
+		if current.children[nextBit] == nil {
+			current.children[nextBit] = &expiringNode{}
+		}
+		current = current.children[nextBit]
+	}
+
+	if current.expires == 0 {
+		this.count++
+	} else if extend && current.expires > expires {
+		return true
+	}
+
+	current.expires = expires
+	return true
+}
+
+// Remove deletes a rule before it expires and reports whether it existed.
+func (this *ExpiringFilter) Remove(subnetMask string) bool {
+	family, numericIP, subnetBits, ok := parseSubnetMask(subnetMask)
+	return ok && this.remove(family, numericIP, subnetBits)
+}
+
+// RemovePrefix behaves like Remove.
+func (this *ExpiringFilter) RemovePrefix(prefix netip.Prefix) bool {
+	family, numericIP, subnetBits, ok := parsePrefix(prefix)
+	return ok && this.remove(family, numericIP, subnetBits)
+}
+func (this *ExpiringFilter) remove(family int, numericIP uint64, subnetBits int) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	current := this.roots[family]
+	for i := 0; i < subnetBits && current != nil; i++ {
+		current = current.children[numericIP<<i>>numericBitMask]
+	}
+
+	if current == nil || current.expires == 0 {
+		return false
+	}
+
+	current.expires = 0
+	this.count--
+	return true // the empty branch is pruned by the next sweep
+}
+
+func (this *ExpiringFilter) Contains(ipAddress string) bool {
+	family, numericIP, ok := parseAddress(ipAddress)
+	return ok && this.contains(family, numericIP)
+}
+func (this *ExpiringFilter) contains(family int, numericIP uint64) bool {
+	_, matched := this.match(family, numericIP)
+	return matched
+}
+func (this *ExpiringFilter) match(family int, numericIP uint64) (int, bool) {
+	now := this.now().UnixNano()
+
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	current := this.roots[family]
+	for i := 0; i < numericBitCount; i++ {
+		current = current.children[numericIP<<i>>numericBitMask]
+
+		if current == nil {
+			break
+		}
+
+		if current.expires > now {
+			return i + 1, true
+		}
+	}
+
+	return 0, false
+}
+
+// Len returns the number of rules, including expired rules that have not been swept yet.
+func (this *ExpiringFilter) Len() int {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	return this.count
+}
+
+// Stats includes expired rules that have not been swept yet.
+func (this *ExpiringFilter) Stats() (stats Stats) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	for family, root := range this.roots {
+		root.stats(family, &stats)
+	}
+
+	stats.Bytes = stats.Nodes * int(unsafe.Sizeof(expiringNode{}))
+	return stats
+}
+func (this *expiringNode) stats(family int, stats *Stats) {
+	stats.Nodes++
+	if this.expires != 0 {
+		stats.addRule(family)
+	}
+
+	for _, child := range this.children {
+		if child != nil {
+			child.stats(family, stats)
+		}
+	}
+}
+
+// Sweep removes expired rules and prunes the branches left empty, returning the number of rules removed.
+func (this *ExpiringFilter) Sweep() int {
+	now := this.now().UnixNano()
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	removed := 0
+	for _, root := range this.roots {
+		for bit, child := range root.children {
+			if child != nil && child.sweep(now, &removed) {
+				root.children[bit] = nil
+			}
+		}
+	}
+
+	this.count -= removed
+	return removed
+}
+
+// sweep reports whether this node is left without rules or children and can be unlinked from its parent.
+func (this *expiringNode) sweep(now int64, removed *int) bool {
+	if this.expires != 0 && this.expires <= now {
+		this.expires = 0
+		*removed++
+	}
+
+	empty := this.expires == 0
+	for bit, child := range this.children {
+		if child != nil && child.sweep(now, removed) {
+			this.children[bit] = nil
+		} else if child != nil {
+			empty = false
+		}
+	}
+
+	return empty
+}
+
+// RunSweeper calls Sweep every interval until ctx is cancelled.
+func (this *ExpiringFilter) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			this.Sweep()
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package ipfilter
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestExpiringRulesLapse(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	filter := NewExpiring(clock.Now)
+
+	Assert(t).That(filter.Add("10.0.0.0/8", clock.now.Add(time.Minute*15))).Equals(true)
+	Assert(t).That(filter.AddPrefix(netip.MustParsePrefix("2600:f0f0:2::/48"), clock.now.Add(time.Minute))).Equals(true)
+	Assert(t).That(filter.Add("192.168.0.0/16", time.Time{})).Equals(true)
+	Assert(t).That(filter.Add("random name", time.Time{})).Equals(false)
+	assertContains(t, filter, "10.1.2.3", "2600:f0f0:2::1", "192.168.1.1")
+
+	clock.now = clock.now.Add(time.Minute)
+	assertContains(t, filter, "10.1.2.3", "192.168.1.1")
+	assertNotContains(t, filter, "2600:f0f0:2::1")
+
+	clock.now = clock.now.Add(time.Hour * 24 * 365)
+	assertContains(t, filter, "192.168.1.1")
+	assertNotContains(t, filter, "10.1.2.3")
+	Assert(t).That(filter.Len()).Equals(3)
+}
+func TestExpiringAddReplacesExpiration(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	filter := NewExpiring(clock.Now)
+
+	filter.Add("1.2.3.4/32", clock.now.Add(time.Minute))
+	filter.Add("1.2.3.4/32", clock.now.Add(time.Hour))
+	clock.now = clock.now.Add(time.Minute * 2)
+
+	assertContains(t, filter, "1.2.3.4")
+	Assert(t).That(filter.Len()).Equals(1)
+}
+func TestExpiringEpochExpirationIsARule(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	filter := NewExpiring(clock.Now)
+
+	filter.Add("1.2.3.4/32", clock.now.Add(time.Hour))
+	Assert(t).That(filter.Add("1.2.3.4/32", time.Unix(0, 0))).Equals(true) // replaces the rule, already expired
+	Assert(t).That(filter.Add("5.6.7.8/32", time.Unix(0, 0))).Equals(true)
+
+	assertNotContains(t, filter, "1.2.3.4", "5.6.7.8")
+	Assert(t).That(filter.Len()).Equals(2)
+	Assert(t).That(filter.Stats().Rules()).Equals(2)
+
+	Assert(t).That(filter.Sweep()).Equals(2)
+	Assert(t).That(filter.Len()).Equals(0)
+}
+func TestExpiringExtendNeverShortens(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	filter := NewExpiring(clock.Now)
+
+	filter.Add("1.2.3.4/32", time.Time{})
+	Assert(t).That(filter.Extend("1.2.3.4/32", clock.now.Add(time.Minute))).Equals(true)
+	filter.Add("5.6.7.8/32", clock.now.Add(time.Hour))
+	Assert(t).That(filter.ExtendPrefix(netip.MustParsePrefix("5.6.7.8/32"), clock.now.Add(time.Minute))).Equals(true)
+	Assert(t).That(filter.Extend("9.9.9.9/32", clock.now.Add(time.Minute))).Equals(true)
+	Assert(t).That(filter.Extend("random name", time.Time{})).Equals(false)
+
+	clock.now = clock.now.Add(time.Minute * 2)
+	assertContains(t, filter, "1.2.3.4", "5.6.7.8")
+	assertNotContains(t, filter, "9.9.9.9")
+
+	Assert(t).That(filter.Extend("9.9.9.9/32", clock.now.Add(time.Minute))).Equals(true) // the expired rule is renewed
+	Assert(t).That(filter.Extend("5.6.7.8/32", time.Time{})).Equals(true)
+	clock.now = clock.now.Add(time.Hour * 2)
+	assertContains(t, filter, "1.2.3.4", "5.6.7.8")
+	Assert(t).That(filter.Len()).Equals(3)
+}
+func TestExpiringNestedRulesExpireIndependently(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	filter := NewExpiring(clock.Now)
+
+	filter.Add("10.0.0.0/8", clock.now.Add(time.Minute))
+	filter.Add("10.1.0.0/16", clock.now.Add(time.Hour))
+	clock.now = clock.now.Add(time.Minute * 2)
+
+	assertContains(t, filter, "10.1.2.3")
+	assertNotContains(t, filter, "10.2.0.0")
+}
+func TestExpiringRemove(t *testing.T) {
+	filter := NewExpiring(nil)
+	filter.Add("10.0.0.0/8", time.Time{})
+
+	Assert(t).That(filter.Remove("10.1.0.0/16")).Equals(false)
+	Assert(t).That(filter.Remove("11.0.0.0/8")).Equals(false)
+	Assert(t).That(filter.RemovePrefix(netip.MustParsePrefix("10.0.0.0/8"))).Equals(true)
+	Assert(t).That(filter.Remove("10.0.0.0/8")).Equals(false)
+	Assert(t).That(filter.Len()).Equals(0)
+	assertNotContains(t, filter, "10.1.2.3")
+}
+func TestExpiringSweepPrunesTrie(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	filter := NewExpiring(clock.Now)
+
+	filter.Add("10.0.0.0/8", clock.now.Add(time.Minute))
+	filter.Add("10.1.0.0/16", clock.now.Add(time.Hour))
+	filter.Add("2600:f0f0:2::/48", clock.now.Add(time.Minute))
+	filter.Add("11.0.0.0/8", time.Time{})
+	filter.Remove("11.0.0.0/8")
+
+	clock.now = clock.now.Add(time.Minute * 2)
+	Assert(t).That(filter.Sweep()).Equals(2)
+	Assert(t).That(filter.Len()).Equals(1)
+	Assert(t).That(filter.roots[ipv6Child].children).Equals([2]*expiringNode{})
+	Assert(t).That(filter.roots[ipv4Child].children[1]).Equals((*expiringNode)(nil)) // 11.0.0.0/8 pruned
+
+	clock.now = clock.now.Add(time.Hour)
+	Assert(t).That(filter.Sweep()).Equals(1)
+	Assert(t).That(filter.roots[ipv4Child].children).Equals([2]*expiringNode{})
+}
+func TestExpiringRunSweeper(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	filter := NewExpiring(clock.Now)
+	filter.Add("10.0.0.0/8", clock.now.Add(-time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { filter.RunSweeper(ctx, time.Millisecond); close(done) }()
+
+	for filter.Len() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+type fakeClock struct{ now time.Time }
+
+func (this *fakeClock) Now() time.Time { return this.now }
@@ -0,0 +1,119 @@
+package ipfilter
+
+import (
+	"errors"
+	"net/netip"
+)
+
+// FlatFilter holds the address space covered by a tree as sorted, disjoint and merged [start, end] intervals in
+// contiguous arrays. IPv6 bounds are 64 bits wide because the tree only keys the first 64 bits of an address.
+type FlatFilter struct {
+	ipv4Starts []uint32
+	ipv4Ends   []uint32
+	ipv6Starts []uint64
+	ipv6Ends   []uint64
+}
+
+func NewFlat(addresses ...string) *FlatFilter {
+	return New(addresses...).(*treeNode).flatten()
+}
+
+// Flatten converts a filter created by New into intervals. Other filters do not expose their rules, so they are
+// refused with ErrUnsupportedFilter rather than converted into a filter that matches nothing; use NewFlat with the
+// rules instead.
+func Flatten(filter Filter) (*FlatFilter, error) {
+	tree, ok := filter.(*treeNode)
+	if !ok {
+		return nil, ErrUnsupportedFilter
+	}
+
+	return tree.flatten(), nil
+}
+func (this *treeNode) flatten() *FlatFilter {
+	flat := &FlatFilter{}
+
+	this.children[ipv4Child].walk(0, 0, func(first, last uint64) {
+		flat.ipv4Starts, flat.ipv4Ends = appendInterval(flat.ipv4Starts, flat.ipv4Ends,
+			uint32(first>>ipv4BitCount), uint32(last>>ipv4BitCount))
+	})
+	this.children[ipv6Child].walk(0, 0, func(first, last uint64) {
+		flat.ipv6Starts, flat.ipv6Ends = appendInterval(flat.ipv6Starts, flat.ipv6Ends, first, last)
+	})
+
+	return flat
+}
+
+// walk visits the banned prefixes below this node in ascending address order.
+func (this *treeNode) walk(numericIP uint64, depth int, visit func(first, last uint64)) {
+	if this.banned {
+		visit(numericIP, numericIP|allNumericBits>>depth)
+		return
+	}
+
+	for bit, child := range this.children {
+		if child != nil {
+			child.walk(numericIP|uint64(bit)<<(numericBitMask-depth), depth+1, visit)
+		}
+	}
+}
+func appendInterval[T uint32 | uint64](starts, ends []T, first, last T) ([]T, []T) {
+	if count := len(ends); count > 0 && ends[count-1]+1 == first {
+		ends[count-1] = last // adjacent to the previous interval
+		return starts, ends
+	}
+
+	return append(starts, first), append(ends, last)
+}
+
+func (this *FlatFilter) Contains(ipAddress string) bool {
+	family, numericIP, ok := parseAddress(ipAddress)
+	return ok && this.contains(family, numericIP)
+}
+func (this *FlatFilter) ContainsAddr(address netip.Addr) bool {
+	family, numericIP, ok := parseAddr(address)
+	return ok && this.contains(family, numericIP)
+}
+func (this *FlatFilter) contains(family int, numericIP uint64) bool {
+	if family == ipv4Child {
+		return containsInterval(this.ipv4Starts, this.ipv4Ends, uint32(numericIP>>ipv4BitCount))
+	} else {
+		return containsInterval(this.ipv6Starts, this.ipv6Ends, numericIP)
+	}
+}
+
+// containsInterval finds the last interval starting at or before the value. The loop always runs log2(n) times
+// and the only data-dependent choice is a conditional move, so there is nothing for the branch predictor to miss.
+func containsInterval[T uint32 | uint64](starts, ends []T, value T) bool {
+	count := len(starts)
+	if count == 0 {
+		return false
+	}
+
+	base := 0
+	for count > 1 {
+		half := count / 2
+		if starts[base+half] <= value {
+			base += half
+		}
+		count -= half
+	}
+
+	return starts[base] <= value && value <= ends[base]
+}
+
+// Stats reports intervals as rules and nodes.
+func (this *FlatFilter) Stats() Stats {
+	return Stats{
+		IPv4Rules: len(this.ipv4Starts),
+		IPv6Rules: len(this.ipv6Starts),
+		Nodes:     len(this.ipv4Starts) + len(this.ipv6Starts),
+		Bytes:     (cap(this.ipv4Starts)+cap(this.ipv4Ends))*4 + (cap(this.ipv6Starts)+cap(this.ipv6Ends))*8,
+	}
+}
+
+// Len returns the number of IPv4 and IPv6 intervals.
+func (this *FlatFilter) Len() (int, int) {
+	return len(this.ipv4Starts), len(this.ipv6Starts)
+}
+
+var ErrUnsupportedFilter = errors.New("only filters created by New can be flattened")
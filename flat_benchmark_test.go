@@ -0,0 +1,17 @@
+package ipfilter
+
+import "testing"
+
+func BenchmarkFlatTest(b *testing.B) {
+	filter := NewFlat(ipAddresses...)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		_ = filter.Contains("1.2.3.4")
+	}
+}
+func BenchmarkFlatBuild(b *testing.B) {
+	benchmarkBuild(b, func(addresses ...string) Filter { return NewFlat(addresses...) })
+}
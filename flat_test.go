@@ -0,0 +1,48 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestFlatMergesAdjacentAndNestedPrefixes(t *testing.T) {
+	filter := NewFlat(
+		"10.0.0.0/24",
+		"10.0.1.0/24", // adjacent: merged with the above
+		"10.0.0.128/25",
+		"192.168.0.0/16",
+		"192.168.7.0/24", // nested: absorbed
+		"2600:f0f0:2::/48",
+		"2600:f0f0:3::/48",
+	)
+
+	ipv4, ipv6 := filter.Len()
+	Assert(t).That([]int{ipv4, ipv6}).Equals([]int{2, 1})
+	assertContains(t, filter, "10.0.0.0", "10.0.1.255", "192.168.255.255", "2600:f0f0:3:ffff::1")
+	assertNotContains(t, filter, "10.0.2.0", "9.255.255.255", "192.169.0.0", "2600:f0f0:4::1")
+	Assert(t).That(filter.ContainsAddr(netip.MustParseAddr("192.168.7.7"))).Equals(true)
+}
+func TestFlatEmpty(t *testing.T) {
+	assertNotContains(t, NewFlat(), "10.0.0.1", "2600:f0f0:2::1")
+}
+func TestFlattenRefusesOtherFilters(t *testing.T) {
+	flat, err := Flatten(New("10.0.0.0/8"))
+	Assert(t).That(err).Equals(nil)
+	assertContains(t, flat, "10.0.0.1")
+
+	flat, err = Flatten(NewCompressed("10.0.0.0/8"))
+	Assert(t).That(flat == nil).Equals(true)
+	Assert(t).That(err).Equals(ErrUnsupportedFilter)
+}
+func TestFlatMatchesTree(t *testing.T) {
+	rules := append([]string{
+		"2600:f0f0:2::/48",
+		"2600:1ff6:7400::/40",
+		"2605:9cc0:1ff0:600::/56",
+		"2a01:578:0:7301::1/128",
+		"128.0.0.0/1",
+		"255.255.255.255/32",
+	}, corpus()...)
+
+	assertSameAnswers(t, New(rules...), NewFlat(rules...), sampleAddresses(rules)...)
+}
@@ -0,0 +1,57 @@
+package grpcfilter
+
+import (
+	"github.com/smarty/ip-filter"
+	"google.golang.org/grpc"
+)
+
+type configuration struct {
+	trusted ipfilter.Filter
+	message string
+}
+
+// UnaryServerInterceptor rejects unary calls from clients contained in blocked with codes.PermissionDenied.
+func UnaryServerInterceptor(blocked ipfilter.Filter, options ...option) grpc.UnaryServerInterceptor {
+	return newInterceptor(blocked, options...).Unary
+}
+
+// StreamServerInterceptor rejects streams from clients contained in blocked with codes.PermissionDenied.
+func StreamServerInterceptor(blocked ipfilter.Filter, options ...option) grpc.StreamServerInterceptor {
+	return newInterceptor(blocked, options...).Stream
+}
+
+func newInterceptor(blocked ipfilter.Filter, options ...option) *interceptor {
+	var config configuration
+	Options.apply(options...)(&config)
+	return &interceptor{blocked: blocked, trusted: config.trusted, message: config.message}
+}
+
+var Options singleton
+
+type singleton struct{}
+type option func(*configuration)
+
+// TrustedProxies lets peers contained in value (such as grpc-gateway or an L7 load balancer) supply the client
+// address through "x-forwarded-for" or "x-real-ip" metadata. Without it, metadata is ignored.
+func (singleton) TrustedProxies(value ipfilter.Filter) option {
+	return func(this *configuration) { this.trusted = value }
+}
+
+// Message sets the status message returned to rejected clients.
+func (singleton) Message(value string) option {
+	return func(this *configuration) { this.message = value }
+}
+
+func (singleton) apply(options ...option) option {
+	return func(this *configuration) {
+		for _, item := range Options.defaults(options...) {
+			item(this)
+		}
+	}
+}
+func (singleton) defaults(options ...option) []option {
+	return append([]option{
+		Options.TrustedProxies(ipfilter.New()),
+		Options.Message("client address is not permitted"),
+	}, options...)
+}
@@ -0,0 +1,21 @@
+module github.com/smarty/ip-filter/grpcfilter
+
+go 1.22
+
+require (
+	github.com/smarty/ip-filter v0.0.0-00010101000000-000000000000
+	google.golang.org/grpc v1.64.0
+)
+
+require (
+	golang.org/x/net v0.22.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+)
+
+// Local development only: modules that depend on grpcfilter ignore this and fetch the version required above. That
+// is a placeholder until the root module has a release with httpfilter and listener; see release-check in the
+// Makefile for the order in which the two modules are tagged.
+replace github.com/smarty/ip-filter => ../
@@ -0,0 +1,89 @@
+package grpcfilter
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+
+	"github.com/smarty/ip-filter"
+	"github.com/smarty/ip-filter/httpfilter"
+	"github.com/smarty/ip-filter/listener"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type interceptor struct {
+	blocked ipfilter.Filter
+	trusted ipfilter.Filter
+	message string
+}
+
+func (this *interceptor) Unary(
+	ctx context.Context, request any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := this.check(ctx); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, request)
+}
+func (this *interceptor) Stream(
+	server any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := this.check(stream.Context()); err != nil {
+		return err
+	}
+
+	return handler(server, stream)
+}
+
+func (this *interceptor) check(ctx context.Context) error {
+	address := this.clientAddress(ctx)
+	if address.IsValid() && ipfilter.ContainsAddr(this.blocked, address) {
+		return status.Error(codes.PermissionDenied, this.message)
+	}
+
+	return nil
+}
+
+// clientAddress returns the peer address, or when the peer is a trusted proxy, the first untrusted address found by
+// walking "x-forwarded-for" right-to-left (falling back to "x-real-ip").
+func (this *interceptor) clientAddress(ctx context.Context) netip.Addr {
+	remote, ok := peer.FromContext(ctx)
+	if !ok {
+		return netip.Addr{}
+	}
+
+	address := listener.RemoteAddress(remote.Addr)
+	if !this.isTrusted(address) {
+		return address
+	}
+
+	incoming, _ := metadata.FromIncomingContext(ctx)
+
+	var hops []string
+	for _, value := range incoming.Get(metadataForwardedFor) {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	if len(hops) == 0 {
+		hops = incoming.Get(metadataRealIP)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		address = httpfilter.ParseHostAddress(hops[i])
+		if !this.isTrusted(address) {
+			return address
+		}
+	}
+
+	return address
+}
+func (this *interceptor) isTrusted(address netip.Addr) bool {
+	return address.IsValid() && ipfilter.ContainsAddr(this.trusted, address)
+}
+
+const (
+	metadataForwardedFor = "x-forwarded-for"
+	metadataRealIP       = "x-real-ip"
+)
@@ -0,0 +1,109 @@
+package grpcfilter
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/smarty/ip-filter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryRejectsBlockedPeer(t *testing.T) {
+	intercept := UnaryServerInterceptor(ipfilter.New("6.6.6.0/24"))
+
+	_, err := intercept(peerContext("6.6.6.6:1111"), nil, nil, okHandler)
+	Assert(t).That(status.Code(err)).Equals(codes.PermissionDenied)
+
+	response, err := intercept(peerContext("[::ffff:7.7.7.7]:1111"), nil, nil, okHandler)
+	Assert(t).That(response).Equals("ok")
+	Assert(t).That(err).Equals(nil)
+
+	response, _ = intercept(context.Background(), nil, nil, okHandler)
+	Assert(t).That(response).Equals("ok")
+}
+func TestMetadataIsOnlyTrustedFromTrustedProxies(t *testing.T) {
+	intercept := UnaryServerInterceptor(ipfilter.New("6.6.6.0/24"),
+		Options.TrustedProxies(ipfilter.New("10.0.0.0/8")), Options.Message("go away"))
+
+	ctx := metadata.NewIncomingContext(peerContext("10.0.0.1:1111"),
+		metadata.Pairs(metadataForwardedFor, "7.7.7.7, 6.6.6.6", metadataForwardedFor, "10.0.0.2"))
+	_, err := intercept(ctx, nil, nil, okHandler)
+	Assert(t).That(status.Convert(err).Message()).Equals("go away")
+
+	ctx = metadata.NewIncomingContext(peerContext("10.0.0.1:1111"), metadata.Pairs(metadataRealIP, "6.6.6.6"))
+	_, err = intercept(ctx, nil, nil, okHandler)
+	Assert(t).That(status.Code(err)).Equals(codes.PermissionDenied)
+
+	ctx = metadata.NewIncomingContext(peerContext("8.8.8.8:1111"), metadata.Pairs(metadataForwardedFor, "6.6.6.6"))
+	_, err = intercept(ctx, nil, nil, okHandler)
+	Assert(t).That(err).Equals(nil)
+
+	ctx = metadata.NewIncomingContext(peerContext("10.0.0.1:1111"),
+		metadata.Pairs(metadataForwardedFor, "6.6.6.6, 7.7.7.7"))
+	_, err = intercept(ctx, nil, nil, okHandler)
+	Assert(t).That(err).Equals(nil)
+}
+func TestInterceptorsAgainstServer(t *testing.T) {
+	socket, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(ipfilter.New("127.0.0.0/8"))),
+		grpc.StreamInterceptor(StreamServerInterceptor(ipfilter.New("127.0.0.0/8"))))
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	go func() { _ = server.Serve(socket) }()
+	defer server.Stop()
+
+	connection, err := grpc.NewClient(socket.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = connection.Close() }()
+	client := grpc_health_v1.NewHealthClient(connection)
+
+	_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	Assert(t).That(status.Code(err)).Equals(codes.PermissionDenied)
+
+	stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	Assert(t).That(status.Code(err)).Equals(codes.PermissionDenied)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func peerContext(address string) context.Context {
+	remote, _ := net.ResolveTCPAddr("tcp", address)
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: remote})
+}
+func okHandler(context.Context, any) (any, error) { return "ok", nil }
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type That struct{ t *testing.T }
+type Assertion struct {
+	*testing.T
+	actual interface{}
+}
+
+func Assert(t *testing.T) *That                       { return &That{t: t} }
+func (this *That) That(actual interface{}) *Assertion { return &Assertion{T: this.t, actual: actual} }
+
+func (this *Assertion) Equals(expected interface{}) {
+	this.Helper()
+	if !reflect.DeepEqual(this.actual, expected) {
+		this.Errorf("\nExpected: %#v\nActual:   %#v", expected, this.actual)
+	}
+}
@@ -0,0 +1,74 @@
+package httpfilter
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+
+	"github.com/smarty/ip-filter"
+)
+
+type configuration struct {
+	resolve      func(*http.Request) netip.Addr
+	rejectStatus int
+	rejectBody   string
+	tagOnly      bool
+	logger       *slog.Logger
+}
+
+func New(inner http.Handler, filter ipfilter.Filter, options ...option) http.Handler {
+	var config configuration
+	Options.apply(options...)(&config)
+	return newHandler(inner, filter, config)
+}
+
+var Options singleton
+
+type singleton struct{}
+type option func(*configuration)
+
+// Resolver sets how the client address of a request is found (default: RemoteAddress). Use NewResolver when the
+// service sits behind proxies.
+func (singleton) Resolver(value func(*http.Request) netip.Addr) option {
+	return func(this *configuration) { this.resolve = value }
+}
+
+// RejectStatus sets the status code written for matching requests (default: 403 Forbidden).
+func (singleton) RejectStatus(value int) option {
+	return func(this *configuration) { this.rejectStatus = value }
+}
+
+// RejectBody sets the response body written for matching requests (default: the status text).
+func (singleton) RejectBody(value string) option {
+	return func(this *configuration) { this.rejectBody = value }
+}
+
+// TagOnly lets matching requests through; handlers inspect the match with FromContext instead.
+func (singleton) TagOnly() option {
+	return func(this *configuration) { this.tagOnly = true }
+}
+
+// Logger logs every matching request with its address, method and path, and whether it was rejected (default: no
+// logging). Attach a handler to route, filter or sample the records.
+func (singleton) Logger(value *slog.Logger) option {
+	return func(this *configuration) { this.logger = value }
+}
+
+func (singleton) apply(options ...option) option {
+	return func(this *configuration) {
+		for _, item := range Options.defaults(options...) {
+			item(this)
+		}
+
+		if len(this.rejectBody) == 0 {
+			this.rejectBody = http.StatusText(this.rejectStatus)
+		}
+	}
+}
+func (singleton) defaults(options ...option) []option {
+	return append([]option{
+		Options.Resolver(RemoteAddress),
+		Options.RejectStatus(http.StatusForbidden),
+		Options.RejectBody(""),
+	}, options...)
+}
@@ -0,0 +1,91 @@
+package httpfilter
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/smarty/ip-filter"
+)
+
+type handler struct {
+	inner        http.Handler
+	filter       ipfilter.Filter
+	resolve      func(*http.Request) netip.Addr
+	rejectStatus int
+	rejectBody   string
+	tagOnly      bool
+	logger       *slog.Logger
+}
+
+func newHandler(inner http.Handler, filter ipfilter.Filter, config configuration) http.Handler {
+	return &handler{
+		inner:        inner,
+		filter:       filter,
+		resolve:      config.resolve,
+		rejectStatus: config.rejectStatus,
+		rejectBody:   config.rejectBody,
+		tagOnly:      config.tagOnly,
+		logger:       config.logger,
+	}
+}
+
+func (this *handler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	result := Result{Address: this.resolve(request)}
+	result.Matched = result.Address.IsValid() && ipfilter.ContainsAddr(this.filter, result.Address)
+
+	if result.Matched && this.logger != nil {
+		this.logger.LogAttrs(request.Context(), slog.LevelInfo, "ipfilter: request matched",
+			slog.String("address", result.Address.String()),
+			slog.String("method", request.Method),
+			slog.String("path", request.URL.Path),
+			slog.Bool("rejected", !this.tagOnly))
+	}
+
+	if result.Matched && !this.tagOnly {
+		http.Error(response, this.rejectBody, this.rejectStatus)
+		return
+	}
+
+	this.inner.ServeHTTP(response, request.WithContext(context.WithValue(request.Context(), contextKey{}, result)))
+}
+
+// Result describes the check made for a request.
+type Result struct {
+	Address netip.Addr // invalid when the client address could not be determined
+	Matched bool
+}
+
+// FromContext returns the result stored by the handler, if any.
+func FromContext(ctx context.Context) (Result, bool) {
+	result, ok := ctx.Value(contextKey{}).(Result)
+	return result, ok
+}
+
+type contextKey struct{}
+
+// RemoteAddress parses request.RemoteAddr, which is usually "ip:port" or "[ipv6%zone]:port". Zones are dropped and
+// IPv4-mapped IPv6 addresses are unmapped so they match IPv4 rules.
+func RemoteAddress(request *http.Request) netip.Addr {
+	return ParseHostAddress(request.RemoteAddr)
+}
+
+// ParseHostAddress accepts "ip", "ip:port", "[ip]" and "[ip]:port" forms.
+func ParseHostAddress(value string) netip.Addr {
+	value = strings.TrimSpace(value)
+
+	if addressPort, err := netip.ParseAddrPort(value); err == nil {
+		return normalize(addressPort.Addr())
+	}
+
+	if address, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")); err == nil {
+		return normalize(address)
+	}
+
+	return netip.Addr{}
+}
+func normalize(address netip.Addr) netip.Addr {
+	return address.WithZone("").Unmap()
+}
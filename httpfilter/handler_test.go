@@ -0,0 +1,123 @@
+package httpfilter
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/smarty/ip-filter"
+)
+
+func TestRejectsMatchingRemoteAddress(t *testing.T) {
+	handler := New(recordingHandler(), ipfilter.New("10.0.0.0/8", "2600:f0f0:2::/48"))
+
+	for _, remoteAddress := range []string{"10.1.2.3:443", "[2600:f0f0:2::1]:443", "[2600:f0f0:2::1%eth0]:443",
+		"[::ffff:10.1.2.3]:443", "10.1.2.3", "[2600:f0f0:2::1]"} {
+		response := serve(handler, remoteAddress)
+		Assert(t).That(response.Code).Equals(http.StatusForbidden)
+		Assert(t).That(response.Body.String()).Equals("Forbidden\n")
+	}
+}
+func TestPassesNonMatchingAndUnknownRemoteAddresses(t *testing.T) {
+	handler := New(recordingHandler(), ipfilter.New("10.0.0.0/8"))
+
+	response := serve(handler, "11.1.2.3:443")
+	Assert(t).That(response.Code).Equals(http.StatusOK)
+	Assert(t).That(response.Body.String()).Equals("11.1.2.3 false")
+
+	response = serve(handler, "@")
+	Assert(t).That(response.Code).Equals(http.StatusOK)
+	Assert(t).That(response.Body.String()).Equals("invalid IP false")
+}
+func TestCustomRejection(t *testing.T) {
+	handler := New(recordingHandler(), ipfilter.New("10.0.0.0/8"),
+		Options.RejectStatus(http.StatusTeapot), Options.RejectBody("go away"))
+
+	response := serve(handler, "10.1.2.3:443")
+	Assert(t).That(response.Code).Equals(http.StatusTeapot)
+	Assert(t).That(response.Body.String()).Equals("go away\n")
+}
+func TestTagOnlyAddsMatchToContext(t *testing.T) {
+	handler := New(recordingHandler(), ipfilter.New("10.0.0.0/8"), Options.TagOnly())
+
+	response := serve(handler, "10.1.2.3:443")
+	Assert(t).That(response.Code).Equals(http.StatusOK)
+	Assert(t).That(response.Body.String()).Equals("10.1.2.3 true")
+}
+func TestLoggerRecordsMatchingRequests(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buffer, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, attribute slog.Attr) slog.Attr {
+			if attribute.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return attribute
+		},
+	}))
+
+	serve(New(recordingHandler(), ipfilter.New("10.0.0.0/8"), Options.Logger(logger)), "10.1.2.3:443")
+	serve(New(recordingHandler(), ipfilter.New("10.0.0.0/8"), Options.Logger(logger), Options.TagOnly()), "10.1.2.4:443")
+	serve(New(recordingHandler(), ipfilter.New("10.0.0.0/8"), Options.Logger(logger)), "11.1.2.3:443")
+
+	Assert(t).That(buffer.String()).Equals(
+		"level=INFO msg=\"ipfilter: request matched\" address=10.1.2.3 method=GET path=/ rejected=true\n" +
+			"level=INFO msg=\"ipfilter: request matched\" address=10.1.2.4 method=GET path=/ rejected=false\n")
+}
+func TestFromContextWithoutHandler(t *testing.T) {
+	_, ok := FromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context())
+	Assert(t).That(ok).Equals(false)
+}
+func TestAgainstServer(t *testing.T) {
+	server := httptest.NewServer(New(recordingHandler(), ipfilter.New("127.0.0.0/8")))
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	Assert(t).That(err).Equals(nil)
+	defer func() { _ = response.Body.Close() }()
+	Assert(t).That(response.StatusCode).Equals(http.StatusForbidden)
+}
+func TestParseHostAddress(t *testing.T) {
+	Assert(t).That(ParseHostAddress(" 1.2.3.4:80 ")).Equals(netip.MustParseAddr("1.2.3.4"))
+	Assert(t).That(ParseHostAddress("[fe80::1%eth0]")).Equals(netip.MustParseAddr("fe80::1"))
+	Assert(t).That(ParseHostAddress("fe80::1")).Equals(netip.MustParseAddr("fe80::1"))
+	Assert(t).That(ParseHostAddress("unix:/tmp/socket")).Equals(netip.Addr{})
+	Assert(t).That(ParseHostAddress("")).Equals(netip.Addr{})
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func recordingHandler() http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		result, _ := FromContext(request.Context())
+		_, _ = io.WriteString(response, result.Address.String())
+		_, _ = io.WriteString(response, map[bool]string{true: " true", false: " false"}[result.Matched])
+	})
+}
+func serve(handler http.Handler, remoteAddress string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.RemoteAddr = remoteAddress
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	return response
+}
+
+type That struct{ t *testing.T }
+type Assertion struct {
+	*testing.T
+	actual interface{}
+}
+
+func Assert(t *testing.T) *That                       { return &That{t: t} }
+func (this *That) That(actual interface{}) *Assertion { return &Assertion{T: this.t, actual: actual} }
+
+func (this *Assertion) Equals(expected interface{}) {
+	this.Helper()
+	if !reflect.DeepEqual(this.actual, expected) {
+		this.Errorf("\nExpected: %#v\nActual:   %#v", expected, this.actual)
+	}
+}
@@ -0,0 +1,100 @@
+package httpfilter
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/smarty/ip-filter"
+)
+
+type resolver struct {
+	trusted ipfilter.Filter
+}
+
+// NewResolver returns a function that finds the client address of a request that may have passed through proxies.
+// Forwarding headers are only believed when the direct peer (RemoteAddr) is a trusted proxy. The RFC 7239 Forwarded
+// header is preferred over X-Forwarded-For; either is walked right-to-left, skipping trusted hops, and the first
+// untrusted hop is the client. X-Real-IP is only consulted when neither header is present. An unparseable hop
+// yields an invalid address because nothing to its left can be trusted.
+func NewResolver(trusted ipfilter.Filter) func(*http.Request) netip.Addr {
+	return (&resolver{trusted: trusted}).Resolve
+}
+
+func (this *resolver) Resolve(request *http.Request) netip.Addr {
+	address := RemoteAddress(request)
+	if !this.isTrusted(address) {
+		return address
+	}
+
+	hops := forwardedHops(request.Header)
+	if len(hops) == 0 {
+		if realIP := request.Header.Get(headerRealIP); len(realIP) > 0 {
+			return ParseHostAddress(realIP)
+		}
+		return address
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		address = ParseHostAddress(hops[i])
+		if !this.isTrusted(address) {
+			return address
+		}
+	}
+
+	return address // every hop is trusted: the leftmost is as close to the client as we can get
+}
+func (this *resolver) isTrusted(address netip.Addr) bool {
+	return address.IsValid() && ipfilter.ContainsAddr(this.trusted, address)
+}
+
+func forwardedHops(header http.Header) (hops []string) {
+	if values := header.Values(headerForwarded); len(values) > 0 {
+		for _, element := range splitQuoted(strings.Join(values, ","), ',') {
+			hops = append(hops, forwardedFor(element))
+		}
+		return hops
+	}
+
+	for _, value := range header.Values(headerForwardedFor) {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// forwardedFor returns the unquoted "for" parameter of a Forwarded element such as `for="[2001:db8::17]:80";by=x`.
+func forwardedFor(element string) string {
+	for _, pair := range splitQuoted(element, ';') {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+		if strings.EqualFold(strings.TrimSpace(key), "for") {
+			return strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+
+	return ""
+}
+func splitQuoted(value string, separator byte) (items []string) {
+	quoted, start := false, 0
+
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			quoted = !quoted
+		case separator:
+			if !quoted {
+				items = append(items, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(items, value[start:])
+}
+
+const (
+	headerForwarded    = "Forwarded"
+	headerForwardedFor = "X-Forwarded-For"
+	headerRealIP       = "X-Real-IP"
+)
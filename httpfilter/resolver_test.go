@@ -0,0 +1,81 @@
+package httpfilter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/smarty/ip-filter"
+)
+
+func TestResolverIgnoresHeadersFromUntrustedPeers(t *testing.T) {
+	resolve := NewResolver(ipfilter.New("10.0.0.0/8"))
+
+	request := newForwardedRequest("1.1.1.1:443", headerForwardedFor, "2.2.2.2")
+	Assert(t).That(resolve(request)).Equals(netip.MustParseAddr("1.1.1.1"))
+}
+func TestResolverSkipsTrustedHopsRightToLeft(t *testing.T) {
+	resolve := NewResolver(ipfilter.New("10.0.0.0/8"))
+
+	request := newForwardedRequest("10.0.0.1:443", headerForwardedFor, "6.6.6.6, 2.2.2.2, 10.0.0.2")
+	Assert(t).That(resolve(request)).Equals(netip.MustParseAddr("2.2.2.2")) // 6.6.6.6 may be spoofed by the client
+}
+func TestResolverJoinsRepeatedHeaders(t *testing.T) {
+	resolve := NewResolver(ipfilter.New("10.0.0.0/8"))
+
+	request := newForwardedRequest("10.0.0.1:443", headerForwardedFor, "2.2.2.2")
+	request.Header.Add(headerForwardedFor, "10.0.0.3, 10.0.0.2")
+	Assert(t).That(resolve(request)).Equals(netip.MustParseAddr("2.2.2.2"))
+}
+func TestResolverReturnsLeftmostWhenEveryHopIsTrusted(t *testing.T) {
+	resolve := NewResolver(ipfilter.New("10.0.0.0/8"))
+
+	request := newForwardedRequest("10.0.0.1:443", headerForwardedFor, "10.0.0.3, 10.0.0.2")
+	Assert(t).That(resolve(request)).Equals(netip.MustParseAddr("10.0.0.3"))
+}
+func TestResolverReturnsInvalidForUnparseableHop(t *testing.T) {
+	resolve := NewResolver(ipfilter.New("10.0.0.0/8"))
+
+	request := newForwardedRequest("10.0.0.1:443", headerForwardedFor, "2.2.2.2, garbage, 10.0.0.2")
+	Assert(t).That(resolve(request).IsValid()).Equals(false)
+}
+func TestResolverPrefersForwarded(t *testing.T) {
+	resolve := NewResolver(ipfilter.New("10.0.0.0/8", "2600:f0f0:2::/48"))
+
+	request := newForwardedRequest("10.0.0.1:443", headerForwarded,
+		`for=192.0.2.60;proto=http;by=203.0.113.43, For="[2001:db8:cafe::17]:4711", for="[2600:f0f0:2::1]";proto=https`)
+	request.Header.Set(headerForwardedFor, "3.3.3.3")
+	Assert(t).That(resolve(request)).Equals(netip.MustParseAddr("2001:db8:cafe::17"))
+
+	request.Header.Set(headerForwarded, `proto=https;by="a,b", for=unknown`)
+	Assert(t).That(resolve(request).IsValid()).Equals(false)
+}
+func TestResolverFallsBackToRealIP(t *testing.T) {
+	resolve := NewResolver(ipfilter.New("10.0.0.0/8"))
+
+	request := newForwardedRequest("10.0.0.1:443", headerRealIP, "4.4.4.4")
+	Assert(t).That(resolve(request)).Equals(netip.MustParseAddr("4.4.4.4"))
+
+	request.Header.Del(headerRealIP)
+	Assert(t).That(resolve(request)).Equals(netip.MustParseAddr("10.0.0.1"))
+}
+func TestHandlerChecksResolvedAddress(t *testing.T) {
+	handler := New(recordingHandler(), ipfilter.New("6.6.6.0/24"),
+		Options.Resolver(NewResolver(ipfilter.New("10.0.0.0/8"))))
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, newForwardedRequest("10.0.0.1:443", headerForwardedFor, "6.6.6.6"))
+	Assert(t).That(response.Code).Equals(http.StatusForbidden)
+
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, newForwardedRequest("10.0.0.1:443", headerForwardedFor, "6.6.6.6, 7.7.7.7"))
+	Assert(t).That(response.Body.String()).Equals("7.7.7.7 false")
+}
+
+func newForwardedRequest(remoteAddress, header, value string) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.RemoteAddr = remoteAddress
+	request.Header.Set(header, value)
+	return request
+}
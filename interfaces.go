@@ -1,5 +1,24 @@
 package ipfilter
 
+import "net/netip"
+
 type Filter interface {
 	Contains(string) bool
 }
+
+// AddrFilter is implemented by filters that check netip.Addr values directly; see ContainsAddr.
+type AddrFilter interface {
+	ContainsAddr(netip.Addr) bool
+}
+
+// numericFilter is implemented by the filters in this package that match nothing they cannot parse; it answers for an
+// address that has already been parsed into its family and left-aligned numeric form. Policy does not implement it,
+// because its default action also applies to addresses that do not parse (such as ::1 and 0.0.0.0).
+type numericFilter interface {
+	contains(family int, numericIP uint64) bool
+}
+
+// prefixFilter is implemented by filters that can report the length of the rule deciding a match.
+type prefixFilter interface {
+	match(family int, numericIP uint64) (subnetBits int, matched bool)
+}
@@ -0,0 +1,24 @@
+// Package testfiles writes the rule and configuration files used by the tests of the reloading packages.
+package testfiles
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Write writes content to directory/name with a modification time of version seconds after the epoch, so that
+// changes are seen regardless of the file system's timestamp resolution, and returns the path.
+func Write(t testing.TB, directory, name, content string, version int64) string {
+	t.Helper()
+
+	path := filepath.Join(directory, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Unix(version, 0), time.Unix(version, 0)); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
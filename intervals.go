@@ -0,0 +1,96 @@
+package ipfilter
+
+import (
+	"math/bits"
+	"net/netip"
+)
+
+// Aggregate returns the smallest set of prefixes covering exactly the address space of the rules, in address order
+// (IPv4 first). Nested rules are dropped and adjacent rules merged; like the filters, IPv6 rules cover whole /64s.
+func Aggregate(rules ...string) []netip.Prefix {
+	return NewFlat(rules...).Prefixes()
+}
+
+// Prefixes returns the smallest set of prefixes covering the intervals, in address order (IPv4 first).
+func (this *FlatFilter) Prefixes() (prefixes []netip.Prefix) {
+	for i := range this.ipv4Starts {
+		prefixes = appendPrefixes(prefixes, ipv4Child, uint64(this.ipv4Starts[i]), uint64(this.ipv4Ends[i]), ipv4BitCount)
+	}
+	for i := range this.ipv6Starts {
+		prefixes = appendPrefixes(prefixes, ipv6Child, this.ipv6Starts[i], this.ipv6Ends[i], ipv6BitCount)
+	}
+
+	return prefixes
+}
+
+// appendPrefixes splits [first, last] into the largest aligned blocks; width is the number of bits in the values.
+func appendPrefixes(prefixes []netip.Prefix, family int, first, last uint64, width int) []netip.Prefix {
+	for {
+		blockBits := min(bits.TrailingZeros64(first), width)
+		for span := uint64(1)<<blockBits - 1; span > last-first; span = uint64(1)<<blockBits - 1 {
+			blockBits--
+		}
+
+		prefixes = append(prefixes, formatPrefix(family, first<<(numericBitCount-width), width-blockBits))
+
+		end := first + (uint64(1)<<blockBits - 1)
+		if end >= last {
+			return prefixes
+		}
+		first = end + 1
+	}
+}
+
+// Subtract returns the address space of this filter that other does not cover.
+func (this *FlatFilter) Subtract(other *FlatFilter) *FlatFilter {
+	result := &FlatFilter{}
+	result.ipv4Starts, result.ipv4Ends = subtractIntervals(this.ipv4Starts, this.ipv4Ends, other.ipv4Starts, other.ipv4Ends)
+	result.ipv6Starts, result.ipv6Ends = subtractIntervals(this.ipv6Starts, this.ipv6Ends, other.ipv6Starts, other.ipv6Ends)
+	return result
+}
+func subtractIntervals[T uint32 | uint64](starts, ends, otherStarts, otherEnds []T) (resultStarts, resultEnds []T) {
+	next := 0
+	for i := range starts {
+		first, last, remaining := starts[i], ends[i], true
+
+		for next < len(otherStarts) && otherEnds[next] < first {
+			next++
+		}
+
+		for j := next; remaining && j < len(otherStarts) && otherStarts[j] <= last; j++ {
+			if otherStarts[j] > first {
+				resultStarts, resultEnds = append(resultStarts, first), append(resultEnds, otherStarts[j]-1)
+			}
+
+			if otherEnds[j] >= last {
+				remaining = false
+			} else {
+				first = otherEnds[j] + 1
+			}
+		}
+
+		if remaining {
+			resultStarts, resultEnds = append(resultStarts, first), append(resultEnds, last)
+		}
+	}
+
+	return resultStarts, resultEnds
+}
+
+// Coverage returns the number of IPv4 addresses and of IPv6 /64 networks covered. The IPv6 count stops at the
+// largest uint64 rather than wrapping.
+func (this *FlatFilter) Coverage() (ipv4Addresses, ipv6Networks uint64) {
+	for i := range this.ipv4Starts {
+		ipv4Addresses += uint64(this.ipv4Ends[i]-this.ipv4Starts[i]) + 1
+	}
+
+	for i := range this.ipv6Starts {
+		sum, carry := bits.Add64(ipv6Networks, this.ipv6Ends[i]-this.ipv6Starts[i], 1)
+		if carry != 0 {
+			return ipv4Addresses, ^uint64(0)
+		}
+		ipv6Networks = sum
+	}
+
+	return ipv4Addresses, ipv6Networks
+}
@@ -0,0 +1,70 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestAggregateMergesAdjacentAndNestedRules(t *testing.T) {
+	prefixes := Aggregate(
+		"10.0.0.0/24",
+		"10.0.1.0/24", // adjacent: merged into 10.0.0.0/23
+		"10.0.0.128/25",
+		"192.168.0.0/24",
+		"192.168.1.0/24",
+		"192.168.2.0/24", // not a sibling of the merged /23
+		"2600:f0f0:2::/48",
+		"2600:f0f0:3::/48",
+		"2a01:578:0:7301::1/128", // IPv6 rules cover a whole /64
+	)
+
+	Assert(t).That(formatPrefixes(prefixes)).Equals([]string{
+		"10.0.0.0/23",
+		"192.168.0.0/23",
+		"192.168.2.0/24",
+		"2600:f0f0:2::/47",
+		"2a01:578:0:7301::/64",
+	})
+}
+func TestAggregateSplitsUnalignedIntervals(t *testing.T) {
+	prefixes := Aggregate("10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/30", "10.0.0.8/32")
+	Assert(t).That(formatPrefixes(prefixes)).Equals([]string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/30", "10.0.0.8/32"})
+
+	// rules based on the zero address are rejected, so the lower halves are written with another base
+	Assert(t).That(formatPrefixes(Aggregate("1.0.0.0/1", "128.0.0.0/1", "1::/1", "8000::/1"))).
+		Equals([]string{"0.0.0.0/0", "::/0"})
+	Assert(t).That(len(Aggregate())).Equals(0)
+}
+func TestAggregateCoversTheSameAddresses(t *testing.T) {
+	rules := corpus()
+	aggregated := formatPrefixes(Aggregate(rules...))
+
+	assertSameAnswers(t, New(rules...), New(aggregated...), sampleAddresses(rules)...)
+	Assert(t).That(len(aggregated) <= len(rules)).Equals(true)
+}
+func TestSubtract(t *testing.T) {
+	previous := NewFlat("10.0.0.0/8", "172.16.0.0/12", "2600:f0f0::/32")
+	current := NewFlat("10.0.0.0/9", "10.200.0.0/16", "192.168.0.0/16", "2600:f0f0::/32")
+
+	Assert(t).That(formatPrefixes(previous.Subtract(current).Prefixes())).Equals([]string{
+		"10.128.0.0/10", "10.192.0.0/13", "10.201.0.0/16", "10.202.0.0/15", "10.204.0.0/14", "10.208.0.0/12",
+		"10.224.0.0/11", "172.16.0.0/12",
+	})
+	Assert(t).That(formatPrefixes(current.Subtract(previous).Prefixes())).Equals([]string{"192.168.0.0/16"})
+	Assert(t).That(len(previous.Subtract(previous).Prefixes())).Equals(0)
+	Assert(t).That(formatPrefixes(previous.Subtract(NewFlat()).Prefixes())).Equals(formatPrefixes(previous.Prefixes()))
+}
+func TestCoverage(t *testing.T) {
+	ipv4, ipv6 := NewFlat("10.0.0.0/8", "10.1.0.0/16", "192.168.1.1/32", "2600:f0f0::/48", "2a01::1/128").Coverage()
+	Assert(t).That([]uint64{ipv4, ipv6}).Equals([]uint64{1<<24 + 1, 1<<16 + 1})
+
+	ipv4, ipv6 = NewFlat("1.0.0.0/1", "128.0.0.0/1", "1::/1", "8000::/1").Coverage()
+	Assert(t).That([]uint64{ipv4, ipv6}).Equals([]uint64{1 << 32, ^uint64(0)})
+}
+
+func formatPrefixes(prefixes []netip.Prefix) (formatted []string) {
+	for _, prefix := range prefixes {
+		formatted = append(formatted, prefix.String())
+	}
+	return formatted
+}
@@ -0,0 +1,52 @@
+package listener
+
+import (
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/smarty/ip-filter"
+)
+
+type configuration struct {
+	callback      func(address netip.Addr, dropped bool)
+	headerTimeout time.Duration
+}
+
+// New wraps inner so that Accept closes connections from addresses contained in filter before returning them.
+func New(inner net.Listener, filter ipfilter.Filter, options ...option) net.Listener {
+	var config configuration
+	Options.apply(options...)(&config)
+	return newListener(inner, filter, config)
+}
+
+var Options singleton
+
+type singleton struct{}
+type option func(*configuration)
+
+// Callback is invoked for every accepted connection with its remote address and whether it was dropped; use it
+// for logging and counters. It runs on the accepting goroutine and should return quickly; PROXY protocol listeners
+// also call it from the goroutines reading headers, so it must be safe for concurrent use there.
+func (singleton) Callback(value func(address netip.Addr, dropped bool)) option {
+	return func(this *configuration) { this.callback = value }
+}
+
+// HeaderTimeout bounds how long a PROXY protocol listener waits for a trusted peer to send its header.
+func (singleton) HeaderTimeout(value time.Duration) option {
+	return func(this *configuration) { this.headerTimeout = value }
+}
+
+func (singleton) apply(options ...option) option {
+	return func(this *configuration) {
+		for _, item := range Options.defaults(options...) {
+			item(this)
+		}
+	}
+}
+func (singleton) defaults(options ...option) []option {
+	return append([]option{
+		Options.Callback(func(netip.Addr, bool) {}),
+		Options.HeaderTimeout(time.Second * 5),
+	}, options...)
+}
@@ -0,0 +1,59 @@
+package listener
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/smarty/ip-filter"
+)
+
+type listener struct {
+	net.Listener
+	filter   ipfilter.Filter
+	callback func(netip.Addr, bool)
+}
+
+func newListener(inner net.Listener, filter ipfilter.Filter, config configuration) net.Listener {
+	return &listener{Listener: inner, filter: filter, callback: config.callback}
+}
+
+func (this *listener) Accept() (net.Conn, error) {
+	for {
+		conn, err := this.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		address := RemoteAddress(conn.RemoteAddr())
+		dropped := address.IsValid() && ipfilter.ContainsAddr(this.filter, address)
+		this.callback(address, dropped)
+
+		if !dropped {
+			return conn, nil
+		}
+
+		_ = conn.Close()
+	}
+}
+
+// RemoteAddress returns the IP of a TCP, UDP or IP network address, unmapping IPv4-mapped IPv6 addresses and
+// dropping zones. Other addresses, such as unix sockets, yield an invalid address.
+func RemoteAddress(address net.Addr) netip.Addr {
+	var result netip.Addr
+
+	switch typed := address.(type) {
+	case *net.TCPAddr:
+		result = typed.AddrPort().Addr()
+	case *net.UDPAddr:
+		result = typed.AddrPort().Addr()
+	case *net.IPAddr:
+		result, _ = netip.AddrFromSlice(typed.IP)
+	case nil:
+	default:
+		if addressPort, err := netip.ParseAddrPort(address.String()); err == nil {
+			result = addressPort.Addr()
+		}
+	}
+
+	return result.WithZone("").Unmap()
+}
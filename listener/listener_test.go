@@ -0,0 +1,99 @@
+package listener
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/smarty/ip-filter"
+)
+
+func TestAcceptsConnectionsNotInFilter(t *testing.T) {
+	var events []string
+	listener := listen(t, ipfilter.New("10.0.0.0/8"), Options.Callback(func(address netip.Addr, dropped bool) {
+		events = append(events, address.String(), map[bool]string{true: "dropped", false: "accepted"}[dropped])
+	}))
+	defer func() { _ = listener.Close() }()
+
+	client := dial(t, listener)
+	defer func() { _ = client.Close() }()
+
+	conn, err := listener.Accept()
+	Assert(t).That(err).Equals(nil)
+	Assert(t).That(conn.RemoteAddr().String()).Equals(client.LocalAddr().String())
+	Assert(t).That(events).Equals([]string{"127.0.0.1", "accepted"})
+	_ = conn.Close()
+}
+func TestDropsConnectionsInFilter(t *testing.T) {
+	dropped := make(chan netip.Addr, 1)
+	listener := listen(t, ipfilter.New("127.0.0.0/8"), Options.Callback(func(address netip.Addr, isDropped bool) {
+		if isDropped {
+			dropped <- address
+		}
+	}))
+
+	accepted := make(chan error, 1)
+	go func() {
+		_, err := listener.Accept()
+		accepted <- err
+	}()
+
+	client := dial(t, listener)
+	defer func() { _ = client.Close() }()
+
+	Assert(t).That(<-dropped).Equals(netip.MustParseAddr("127.0.0.1"))
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	_, err := client.Read(make([]byte, 1))
+	var netError net.Error
+	Assert(t).That(err != nil).Equals(true)
+	Assert(t).That(errors.As(err, &netError) && netError.Timeout()).Equals(false) // closed by the server
+
+	_ = listener.Close()
+	Assert(t).That(<-accepted != nil).Equals(true)
+}
+func TestRemoteAddress(t *testing.T) {
+	Assert(t).That(RemoteAddress(&net.TCPAddr{IP: net.ParseIP("1.2.3.4"), Port: 1})).Equals(netip.MustParseAddr("1.2.3.4"))
+	Assert(t).That(RemoteAddress(&net.UDPAddr{IP: net.ParseIP("fe80::1"), Zone: "eth0"})).
+		Equals(netip.MustParseAddr("fe80::1"))
+	Assert(t).That(RemoteAddress(&net.IPAddr{IP: net.ParseIP("::ffff:1.2.3.4")})).Equals(netip.MustParseAddr("1.2.3.4"))
+	Assert(t).That(RemoteAddress(&net.UnixAddr{Name: "/tmp/socket", Net: "unix"})).Equals(netip.Addr{})
+	Assert(t).That(RemoteAddress(nil)).Equals(netip.Addr{})
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func listen(t *testing.T, filter ipfilter.Filter, options ...option) net.Listener {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(inner, filter, options...)
+}
+func dial(t *testing.T, listener net.Listener) net.Conn {
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type That struct{ t *testing.T }
+type Assertion struct {
+	*testing.T
+	actual interface{}
+}
+
+func Assert(t *testing.T) *That                       { return &That{t: t} }
+func (this *That) That(actual interface{}) *Assertion { return &Assertion{T: this.t, actual: actual} }
+
+func (this *Assertion) Equals(expected interface{}) {
+	this.Helper()
+	if !reflect.DeepEqual(this.actual, expected) {
+		this.Errorf("\nExpected: %#v\nActual:   %#v", expected, this.actual)
+	}
+}
@@ -0,0 +1,274 @@
+package listener
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smarty/ip-filter"
+)
+
+// NewProxyProtocol wraps a listener that sits behind proxies speaking the PROXY protocol (v1 text or v2 binary).
+// The header is only read from peers contained in trusted; connections from any other peer are treated as direct
+// clients. The real client address (from the header, or the peer address otherwise) is checked against blocked
+// and is reported by RemoteAddr on the returned connection. Connections with a missing or malformed header from a
+// trusted peer are closed. Each header is read on its own goroutine, bounded by Options.HeaderTimeout, so a peer
+// that stalls does not hold up other connections; Accept returns connections in the order their headers complete.
+func NewProxyProtocol(inner net.Listener, trusted, blocked ipfilter.Filter, options ...option) net.Listener {
+	var config configuration
+	Options.apply(options...)(&config)
+	return &proxyListener{
+		Listener:      inner,
+		trusted:       trusted,
+		blocked:       blocked,
+		headerTimeout: config.headerTimeout,
+		callback:      config.callback,
+		accepted:      make(chan acceptedConn),
+		stopped:       make(chan struct{}),
+	}
+}
+
+type proxyListener struct {
+	net.Listener
+	trusted       ipfilter.Filter
+	blocked       ipfilter.Filter
+	headerTimeout time.Duration
+	callback      func(netip.Addr, bool)
+
+	start    sync.Once
+	accepted chan acceptedConn
+	stopped  chan struct{} // closed when the inner listener fails for good; err is set before
+	err      error
+}
+type acceptedConn struct {
+	conn net.Conn
+	err  error
+}
+
+func (this *proxyListener) Accept() (net.Conn, error) {
+	this.start.Do(func() { go this.acceptLoop() })
+
+	select {
+	case accepted := <-this.accepted:
+		return accepted.conn, accepted.err
+	case <-this.stopped:
+		return nil, this.err
+	}
+}
+
+// acceptLoop accepts from the inner listener until it fails with an error that is not temporary. Connections from
+// trusted peers have their headers read on a goroutine each; the rest are checked inline.
+func (this *proxyListener) acceptLoop() {
+	for {
+		conn, err := this.Listener.Accept()
+		if err != nil {
+			if temporary, ok := err.(interface{ Temporary() bool }); ok && temporary.Temporary() {
+				this.deliver(acceptedConn{err: err}) // let the caller back off, as net/http does
+				continue
+			}
+
+			this.err = err
+			close(this.stopped)
+			return
+		}
+
+		address := RemoteAddress(conn.RemoteAddr())
+		if address.IsValid() && ipfilter.ContainsAddr(this.trusted, address) {
+			go this.acceptProxied(conn, address)
+		} else {
+			this.finish(conn, address, address.IsValid() && ipfilter.ContainsAddr(this.blocked, address))
+		}
+	}
+}
+func (this *proxyListener) acceptProxied(conn net.Conn, address netip.Addr) {
+	proxied, err := this.readHeader(conn)
+	if err != nil {
+		this.finish(conn, address, true)
+		return
+	}
+
+	address = RemoteAddress(proxied.RemoteAddr())
+	this.finish(proxied, address, address.IsValid() && ipfilter.ContainsAddr(this.blocked, address))
+}
+func (this *proxyListener) finish(conn net.Conn, address netip.Addr, dropped bool) {
+	this.callback(address, dropped)
+
+	if dropped || !this.deliver(acceptedConn{conn: conn}) {
+		_ = conn.Close()
+	}
+}
+
+// deliver hands a connection or error to Accept and reports whether it was taken before the listener stopped.
+func (this *proxyListener) deliver(accepted acceptedConn) bool {
+	select {
+	case this.accepted <- accepted:
+		return true
+	case <-this.stopped:
+		return false
+	}
+}
+func (this *proxyListener) readHeader(conn net.Conn) (*ProxyConn, error) {
+	_ = conn.SetReadDeadline(time.Now().Add(this.headerTimeout))
+	defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
+
+	reader := bufio.NewReader(conn)
+	source, err := readProxyHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	remote := conn.RemoteAddr()
+	if source.IsValid() {
+		remote = net.TCPAddrFromAddrPort(source)
+	}
+
+	return &ProxyConn{Conn: conn, reader: reader, remote: remote}, nil
+}
+
+// ProxyConn is returned by a PROXY protocol listener for connections from trusted proxies.
+type ProxyConn struct {
+	net.Conn
+	reader *bufio.Reader
+	remote net.Addr
+}
+
+func (this *ProxyConn) Read(buffer []byte) (int, error) { return this.reader.Read(buffer) }
+
+// RemoteAddr returns the client address carried in the PROXY header. For LOCAL (v2) and UNKNOWN (v1) headers, such
+// as proxy health checks, it is the address of the proxy itself.
+func (this *ProxyConn) RemoteAddr() net.Addr { return this.remote }
+
+// ProxyAddr returns the address of the proxy that sent the header.
+func (this *ProxyConn) ProxyAddr() net.Addr { return this.Conn.RemoteAddr() }
+
+// readProxyHeader returns the source address of a v1 or v2 header, or an invalid address when the header carries
+// no address (v1 UNKNOWN, v2 LOCAL or a non-IP family).
+func readProxyHeader(reader *bufio.Reader) (netip.AddrPort, error) {
+	signature, err := reader.Peek(len(proxyV2Signature))
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+
+	if bytes.Equal(signature, proxyV2Signature) {
+		return readProxyV2Header(reader)
+	}
+
+	if bytes.HasPrefix(signature, proxyV1Prefix) {
+		return readProxyV1Header(reader)
+	}
+
+	return netip.AddrPort{}, ErrMalformedProxyHeader
+}
+
+// readProxyV1Header parses "PROXY TCP4|TCP6 <source> <destination> <source port> <destination port>\r\n" or
+// "PROXY UNKNOWN ...\r\n".
+func readProxyV1Header(reader *bufio.Reader) (netip.AddrPort, error) {
+	line, err := readLine(reader, proxyV1MaximumLength)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+
+	fields := strings.Split(strings.TrimSuffix(line, "\r\n"), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return netip.AddrPort{}, nil
+	}
+
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return netip.AddrPort{}, ErrMalformedProxyHeader
+	}
+
+	address, err := netip.ParseAddr(fields[2])
+	if err != nil || address.Is4() != (fields[1] == "TCP4") {
+		return netip.AddrPort{}, ErrMalformedProxyHeader
+	}
+
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return netip.AddrPort{}, ErrMalformedProxyHeader
+	}
+
+	return netip.AddrPortFrom(address, uint16(port)), nil
+}
+func readLine(reader *bufio.Reader, limit int) (string, error) {
+	var line []byte
+
+	for len(line) < limit {
+		character, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		line = append(line, character)
+		if character == '\n' {
+			if !bytes.HasSuffix(line, []byte("\r\n")) {
+				break
+			}
+			return string(line), nil
+		}
+	}
+
+	return "", ErrMalformedProxyHeader
+}
+
+// readProxyV2Header parses the 16 byte fixed header (signature, version/command, family/protocol, length) and the
+// address block, skipping any TLVs.
+func readProxyV2Header(reader *bufio.Reader) (netip.AddrPort, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return netip.AddrPort{}, err
+	}
+
+	versionCommand, family := header[12], header[13]
+	if versionCommand>>4 != 2 || versionCommand&0x0F > proxyV2CommandProxy {
+		return netip.AddrPort{}, ErrMalformedProxyHeader
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return netip.AddrPort{}, err
+	}
+
+	if versionCommand&0x0F == proxyV2CommandLocal {
+		return netip.AddrPort{}, nil
+	}
+
+	switch family >> 4 {
+	case proxyV2FamilyIPv4:
+		if len(payload) < 12 {
+			return netip.AddrPort{}, ErrMalformedProxyHeader
+		}
+		address := netip.AddrFrom4([4]byte(payload[0:4]))
+		return netip.AddrPortFrom(address, binary.BigEndian.Uint16(payload[8:10])), nil
+	case proxyV2FamilyIPv6:
+		if len(payload) < 36 {
+			return netip.AddrPort{}, ErrMalformedProxyHeader
+		}
+		address := netip.AddrFrom16([16]byte(payload[0:16]))
+		return netip.AddrPortFrom(address, binary.BigEndian.Uint16(payload[32:34])), nil
+	default:
+		return netip.AddrPort{}, nil // AF_UNSPEC or AF_UNIX: no IP to report
+	}
+}
+
+var ErrMalformedProxyHeader = errors.New("malformed PROXY protocol header")
+
+var (
+	proxyV1Prefix    = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+const (
+	proxyV1MaximumLength = 107
+	proxyV2CommandLocal  = 0x0
+	proxyV2CommandProxy  = 0x1
+	proxyV2FamilyIPv4    = 0x1
+	proxyV2FamilyIPv6    = 0x2
+)
@@ -0,0 +1,170 @@
+package listener
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smarty/ip-filter"
+)
+
+func TestReadProxyV1Header(t *testing.T) {
+	assertProxyHeader(t, "PROXY TCP4 7.7.7.7 10.0.0.1 1111 443\r\n", "7.7.7.7:1111", nil)
+	assertProxyHeader(t, "PROXY TCP6 2600:f0f0:2::1 ::1 1111 443\r\n", "[2600:f0f0:2::1]:1111", nil)
+	assertProxyHeader(t, "PROXY UNKNOWN\r\n", "invalid AddrPort", nil)
+	assertProxyHeader(t, "PROXY UNKNOWN 1.1.1.1 2.2.2.2 1 2\r\n", "invalid AddrPort", nil)
+
+	assertProxyHeader(t, "PROXY TCP4 2600::1 10.0.0.1 1111 443\r\n", "invalid AddrPort", ErrMalformedProxyHeader)
+	assertProxyHeader(t, "PROXY TCP4 7.7.7.7 10.0.0.1 99999 443\r\n", "invalid AddrPort", ErrMalformedProxyHeader)
+	assertProxyHeader(t, "PROXY TCP4 7.7.7.7 10.0.0.1 1111\r\n", "invalid AddrPort", ErrMalformedProxyHeader)
+	assertProxyHeader(t, "PROXY TCP4 7.7.7.7 10.0.0.1 1111 443\n", "invalid AddrPort", ErrMalformedProxyHeader)
+	assertProxyHeader(t, "PROXY "+strings.Repeat("A", 120)+"\r\n", "invalid AddrPort", ErrMalformedProxyHeader)
+	assertProxyHeader(t, "GET / HTTP/1.1\r\n", "invalid AddrPort", ErrMalformedProxyHeader)
+}
+func TestReadProxyV2Header(t *testing.T) {
+	ipv4 := append(netip.MustParseAddr("7.7.7.7").AsSlice(), 10, 0, 0, 1, 0x04, 0x57, 0x01, 0xBB)
+	assertProxyHeader(t, proxyV2Header(0x21, 0x11, ipv4), "7.7.7.7:1111", nil)
+	assertProxyHeader(t, proxyV2Header(0x21, 0x12, append(ipv4, 0x04, 0x00, 0x01, 0x00)), "7.7.7.7:1111", nil) // TLV
+
+	ipv6 := append(netip.MustParseAddr("2600:f0f0:2::1").AsSlice(), make([]byte, 16)...)
+	assertProxyHeader(t, proxyV2Header(0x21, 0x21, append(ipv6, 0x04, 0x57, 0x01, 0xBB)), "[2600:f0f0:2::1]:1111", nil)
+
+	assertProxyHeader(t, proxyV2Header(0x20, 0x00, nil), "invalid AddrPort", nil) // LOCAL
+	assertProxyHeader(t, proxyV2Header(0x21, 0x31, make([]byte, 216)), "invalid AddrPort", nil)
+	assertProxyHeader(t, proxyV2Header(0x21, 0x11, ipv4[:8]), "invalid AddrPort", ErrMalformedProxyHeader)
+	assertProxyHeader(t, proxyV2Header(0x11, 0x11, ipv4), "invalid AddrPort", ErrMalformedProxyHeader)
+	assertProxyHeader(t, proxyV2Header(0x22, 0x11, ipv4), "invalid AddrPort", ErrMalformedProxyHeader)
+	assertProxyHeader(t, proxyV2Header(0x21, 0x11, ipv4)[:20], "invalid AddrPort", io.ErrUnexpectedEOF)
+}
+
+func TestProxyProtocolExposesRealClientAddress(t *testing.T) {
+	listener := listenProxy(t, ipfilter.New("127.0.0.0/8"), ipfilter.New("6.6.6.0/24"))
+	defer func() { _ = listener.Close() }()
+
+	client := dial(t, listener)
+	defer func() { _ = client.Close() }()
+	_, _ = io.WriteString(client, "PROXY TCP4 7.7.7.7 127.0.0.1 1111 443\r\nhello")
+
+	conn, err := listener.Accept()
+	Assert(t).That(err).Equals(nil)
+	defer func() { _ = conn.Close() }()
+
+	Assert(t).That(conn.RemoteAddr().String()).Equals("7.7.7.7:1111")
+	Assert(t).That(conn.(*ProxyConn).ProxyAddr().String()).Equals(client.LocalAddr().String())
+	Assert(t).That(readAll(conn, 5)).Equals("hello")
+}
+func TestProxyProtocolDropsBlockedClientsAndMalformedHeaders(t *testing.T) {
+	events := make(chan string, 8)
+	listener := listenProxy(t, ipfilter.New("127.0.0.0/8"), ipfilter.New("6.6.6.0/24"),
+		Options.Callback(func(address netip.Addr, dropped bool) {
+			events <- address.String() + " " + map[bool]string{true: "dropped", false: "accepted"}[dropped]
+		}),
+		Options.HeaderTimeout(time.Millisecond*50))
+	defer func() { _ = listener.Close() }()
+
+	for _, header := range []string{"PROXY TCP4 6.6.6.6 127.0.0.1 1111 443\r\n", "garbage-garbage\r\n", "PRO"} {
+		client := dial(t, listener)
+		defer func() { _ = client.Close() }()
+		_, _ = io.WriteString(client, header)
+	}
+	client := dial(t, listener)
+	defer func() { _ = client.Close() }()
+	_, _ = io.WriteString(client, "PROXY UNKNOWN\r\n")
+
+	conn, err := listener.Accept()
+	Assert(t).That(err).Equals(nil)
+	_ = conn.Close()
+
+	var received []string
+	for len(received) < 4 {
+		received = append(received, <-events) // the truncated header is only dropped once it times out
+	}
+	sort.Strings(received)
+	Assert(t).That(received).Equals([]string{
+		"127.0.0.1 accepted",
+		"127.0.0.1 dropped", // malformed
+		"127.0.0.1 dropped", // timed out
+		"6.6.6.6 dropped",
+	})
+}
+func TestProxyProtocolStalledHeaderDoesNotDelayOtherClients(t *testing.T) {
+	listener := listenProxy(t, ipfilter.New("127.0.0.0/8"), ipfilter.New("6.6.6.0/24"))
+	defer func() { _ = listener.Close() }()
+
+	silent := dial(t, listener) // trusted, but never sends its header
+	defer func() { _ = silent.Close() }()
+
+	client := dial(t, listener)
+	defer func() { _ = client.Close() }()
+	_, _ = io.WriteString(client, "PROXY TCP4 7.7.7.7 127.0.0.1 1111 443\r\n")
+
+	started := time.Now()
+	conn, err := listener.Accept()
+	Assert(t).That(err).Equals(nil)
+	defer func() { _ = conn.Close() }()
+
+	Assert(t).That(conn.RemoteAddr().String()).Equals("7.7.7.7:1111")
+	Assert(t).That(time.Since(started) < time.Second).Equals(true) // the header timeout is 5 seconds
+}
+func TestProxyProtocolAcceptFailsOnceClosed(t *testing.T) {
+	listener := listenProxy(t, ipfilter.New("127.0.0.0/8"), ipfilter.New("6.6.6.0/24"))
+
+	silent := dial(t, listener)
+	defer func() { _ = silent.Close() }()
+
+	go func() { time.Sleep(time.Millisecond * 10); _ = listener.Close() }()
+	_, err := listener.Accept()
+	Assert(t).That(errors.Is(err, net.ErrClosed)).Equals(true)
+
+	_, err = listener.Accept()
+	Assert(t).That(errors.Is(err, net.ErrClosed)).Equals(true)
+}
+func TestProxyProtocolIgnoresHeadersFromUntrustedPeers(t *testing.T) {
+	listener := listenProxy(t, ipfilter.New("10.0.0.0/8"), ipfilter.New("6.6.6.0/24"))
+	defer func() { _ = listener.Close() }()
+
+	client := dial(t, listener)
+	defer func() { _ = client.Close() }()
+	_, _ = io.WriteString(client, "PROXY TCP4 7.7.7.7 127.0.0.1 1111 443\r\n")
+
+	conn, err := listener.Accept()
+	Assert(t).That(err).Equals(nil)
+	defer func() { _ = conn.Close() }()
+
+	Assert(t).That(conn.RemoteAddr().String()).Equals(client.LocalAddr().String())
+	Assert(t).That(readAll(conn, 6)).Equals("PROXY ")
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func assertProxyHeader(t *testing.T, header, expected string, expectedErr error) {
+	t.Helper()
+	source, err := readProxyHeader(bufio.NewReader(strings.NewReader(header)))
+	Assert(t).That(source.String()).Equals(expected)
+	Assert(t).That(err).Equals(expectedErr)
+}
+func proxyV2Header(versionCommand, family byte, payload []byte) string {
+	header := append(append([]byte{}, proxyV2Signature...), versionCommand, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:], uint16(len(payload)))
+	return string(append(header, payload...))
+}
+func listenProxy(t *testing.T, trusted, blocked ipfilter.Filter, options ...option) net.Listener {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewProxyProtocol(inner, trusted, blocked, options...)
+}
+func readAll(conn net.Conn, length int) string {
+	buffer := make([]byte, length)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _ = io.ReadFull(conn, buffer)
+	return string(buffer)
+}
@@ -0,0 +1,131 @@
+package ipfilter
+
+import (
+	"context"
+	"log/slog"
+	"net/netip"
+	"sync/atomic"
+)
+
+// LoggedFilter wraps a filter and logs a sample of its matches. The matching rule is included when the wrapped
+// filter can report it (New, NewCompressed, NewCounting and NewExpiring); CompiledFilter and FlatFilter expand and
+// merge their rules, so only the address is logged for them.
+type LoggedFilter struct {
+	filter  Filter
+	logger  *slog.Logger
+	every   uint64
+	matches atomic.Uint64
+}
+
+// NewLogged builds a filter from rules with constructor (New, NewCompressed, ...), logging every rule it skips
+// together with the reason, and wraps the result with Logged.
+func NewLogged[F Filter](constructor func(...string) F, rules []string, options ...loggedOption) *LoggedFilter {
+	var config loggedConfiguration
+	LoggedOptions.apply(options...)(&config)
+
+	for _, rule := range rules {
+		if _, err := ParseRule(rule); err != nil {
+			config.logger.Warn("ipfilter: rule rejected", slog.String("rule", rule), slog.String("reason", err.Error()))
+		}
+	}
+
+	return newLogged(constructor(rules...), config)
+}
+
+// Logged wraps an existing filter so that its matches are logged.
+func Logged(filter Filter, options ...loggedOption) *LoggedFilter {
+	var config loggedConfiguration
+	LoggedOptions.apply(options...)(&config)
+	return newLogged(filter, config)
+}
+func newLogged(filter Filter, config loggedConfiguration) *LoggedFilter {
+	return &LoggedFilter{filter: filter, logger: config.logger, every: uint64(config.sampleMatches)}
+}
+
+func (this *LoggedFilter) Contains(ipAddress string) bool {
+	matched := this.filter.Contains(ipAddress)
+	if count, sampled := this.sample(matched); sampled {
+		family, numericIP, _ := parseAddress(ipAddress)
+		this.logMatch(ipAddress, family, numericIP, count)
+	}
+	return matched
+}
+func (this *LoggedFilter) ContainsAddr(address netip.Addr) bool {
+	matched := ContainsAddr(this.filter, address)
+	if count, sampled := this.sample(matched); sampled {
+		family, numericIP, _ := parseAddr(address)
+		this.logMatch(address.String(), family, numericIP, count)
+	}
+	return matched
+}
+func (this *LoggedFilter) sample(matched bool) (uint64, bool) {
+	if !matched {
+		return 0, false
+	}
+
+	count := this.matches.Add(1)
+	return count, this.every > 0 && (count-1)%this.every == 0 // always log the first match
+}
+func (this *LoggedFilter) logMatch(address string, family int, numericIP uint64, count uint64) {
+	attributes := []slog.Attr{slog.String("address", address), slog.Uint64("matches", count)}
+
+	if filter, ok := this.filter.(prefixFilter); ok {
+		if subnetBits, matched := filter.match(family, numericIP); matched {
+			attributes = append(attributes, slog.String("prefix", formatPrefix(family, numericIP, subnetBits).String()))
+		}
+	}
+
+	this.logger.LogAttrs(context.Background(), slog.LevelInfo, "ipfilter: address matched", attributes...)
+}
+
+// Stats forwards to the wrapped filter, if it reports stats.
+func (this *LoggedFilter) Stats() Stats {
+	if filter, ok := this.filter.(interface{ Stats() Stats }); ok {
+		return filter.Stats()
+	}
+
+	return Stats{}
+}
+
+// Matches returns the number of matches seen, logged or not.
+func (this *LoggedFilter) Matches() uint64 { return this.matches.Load() }
+
+type loggedConfiguration struct {
+	logger        *slog.Logger
+	sampleMatches int
+}
+
+// LoggedOptions configures NewLogged and Logged.
+var LoggedOptions loggedSingleton
+
+type loggedSingleton struct{}
+type loggedOption func(*loggedConfiguration)
+
+// Logger sets where rejected rules and matches are logged (default: slog.Default()). Attach a handler to route,
+// filter or rate limit the records.
+func (loggedSingleton) Logger(value *slog.Logger) loggedOption {
+	return func(this *loggedConfiguration) { this.logger = value }
+}
+
+// SampleMatches logs the first match and then every nth match (default: 1, every match); 0 disables match logging.
+func (loggedSingleton) SampleMatches(every int) loggedOption {
+	return func(this *loggedConfiguration) { this.sampleMatches = max(every, 0) }
+}
+
+func (loggedSingleton) apply(options ...loggedOption) loggedOption {
+	return func(this *loggedConfiguration) {
+		for _, item := range LoggedOptions.defaults(options...) {
+			item(this)
+		}
+
+		if this.logger == nil {
+			this.logger = slog.Default()
+		}
+	}
+}
+func (loggedSingleton) defaults(options ...loggedOption) []loggedOption {
+	return append([]loggedOption{
+		LoggedOptions.Logger(slog.Default()),
+		LoggedOptions.SampleMatches(1),
+	}, options...)
+}
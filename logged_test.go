@@ -0,0 +1,129 @@
+package ipfilter
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestParseRuleExplainsRejections(t *testing.T) {
+	prefix, err := ParseRule("10.1.2.3/16")
+	Assert(t).That(prefix).Equals(netip.MustParsePrefix("10.1.0.0/16"))
+	Assert(t).That(err).Equals(nil)
+
+	prefix, err = ParseRule("2600:f0f0:2:1:2:3::/96")
+	Assert(t).That(prefix).Equals(netip.MustParsePrefix("2600:f0f0:2:1::/64"))
+	Assert(t).That(err).Equals(nil)
+
+	for rule, expected := range map[string]error{
+		"":              ErrEmptyRule,
+		"10.0.0.1":      ErrMissingSubnetBits,
+		"10.0.0.0/0":    ErrInvalidSubnetBits,
+		"10.0.0.0/33":   ErrInvalidSubnetBits,
+		"10.0.0.0/x":    ErrInvalidSubnetBits,
+		"2600::/-1":     ErrInvalidSubnetBits,
+		"0.0.0.0/8":     ErrInvalidAddress,
+		"10.0.0/8":      ErrInvalidAddress,
+		"random name/8": ErrInvalidAddress,
+		"260g::/16":     ErrInvalidAddress,
+	} {
+		_, err := ParseRule(rule)
+		Assert(t).That(err).Equals(expected)
+	}
+}
+func TestParseRuleAgreesWithConstructors(t *testing.T) {
+	for _, rule := range corpus() {
+		_, err := ParseRule(rule)
+		Assert(t).That(err).Equals(nil)
+	}
+}
+
+func TestNewLoggedLogsRejectedRules(t *testing.T) {
+	logger, records := recordLogs()
+	filter := NewLogged(NewCompressed, []string{"10.0.0.0/8", "10.0.0.1", "random name"}, LoggedOptions.Logger(logger))
+
+	Assert(t).That(records()).Equals([]map[string]any{
+		{"level": "WARN", "msg": "ipfilter: rule rejected", "rule": "10.0.0.1", "reason": ErrMissingSubnetBits.Error()},
+		{"level": "WARN", "msg": "ipfilter: rule rejected", "rule": "random name", "reason": ErrMissingSubnetBits.Error()},
+	})
+	Assert(t).That(filter.Stats().Rules()).Equals(1)
+}
+func TestLoggedFilterLogsMatchesWithPrefix(t *testing.T) {
+	logger, records := recordLogs()
+	filter := NewLogged(New, []string{"10.0.0.0/8", "10.1.0.0/16", "2600:f0f0:2::/48"}, LoggedOptions.Logger(logger))
+
+	assertContains(t, filter, "10.1.2.3", "2600:f0f0:2::1")
+	assertNotContains(t, filter, "11.1.2.3")
+	Assert(t).That(filter.ContainsAddr(netip.MustParseAddr("10.9.9.9"))).Equals(true)
+
+	Assert(t).That(records()).Equals([]map[string]any{
+		{"level": "INFO", "msg": "ipfilter: address matched", "address": "10.1.2.3", "matches": 1.0, "prefix": "10.0.0.0/8"},
+		{"level": "INFO", "msg": "ipfilter: address matched", "address": "2600:f0f0:2::1", "matches": 2.0,
+			"prefix": "2600:f0f0:2::/48"},
+		{"level": "INFO", "msg": "ipfilter: address matched", "address": "10.9.9.9", "matches": 3.0, "prefix": "10.0.0.0/8"},
+	})
+}
+func TestLoggedFilterSamplesMatches(t *testing.T) {
+	logger, records := recordLogs()
+	filter := Logged(NewCompiled("10.0.0.0/8"), LoggedOptions.Logger(logger), LoggedOptions.SampleMatches(3))
+
+	for i := 0; i < 7; i++ {
+		filter.Contains("10.1.2.3")
+	}
+
+	logged := records()
+	Assert(t).That(len(logged)).Equals(3) // matches 1, 4 and 7
+	Assert(t).That(logged[2]["matches"]).Equals(7.0)
+	Assert(t).That(logged[2]["prefix"]).Equals(nil) // compiled filters do not keep their rules
+	Assert(t).That(filter.Matches()).Equals(uint64(7))
+}
+func TestLoggedFilterWithoutMatchLogging(t *testing.T) {
+	logger, records := recordLogs()
+	filter := Logged(New("10.0.0.0/8"), LoggedOptions.Logger(logger), LoggedOptions.SampleMatches(0))
+
+	assertContains(t, filter, "10.1.2.3")
+	Assert(t).That(len(records())).Equals(0)
+	Assert(t).That(filter.Matches()).Equals(uint64(1))
+}
+func TestMatchReportsShortestRule(t *testing.T) {
+	rules := append([]string{"10.0.0.0/8", "10.1.0.0/16", "2600:f0f0:2::/48"}, corpus()...)
+	expiring := NewExpiring(time.Now)
+	for _, rule := range rules {
+		expiring.Add(rule, time.Time{})
+	}
+
+	tree, addresses := New(rules...).(prefixFilter), sampleAddresses(rules)
+	for _, filter := range []prefixFilter{NewCompressed(rules...).(prefixFilter), NewCounting(rules...), expiring} {
+		for _, address := range addresses {
+			family, numericIP, _ := parseAddress(address)
+			expectedBits, expectedMatch := tree.match(family, numericIP)
+			subnetBits, matched := filter.match(family, numericIP)
+			Assert(t).That([]any{address, subnetBits, matched}).Equals([]any{address, expectedBits, expectedMatch})
+		}
+	}
+}
+
+func recordLogs() (*slog.Logger, func() []map[string]any) {
+	buffer := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buffer, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, attribute slog.Attr) slog.Attr {
+			if attribute.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return attribute
+		},
+	}))
+
+	return logger, func() (records []map[string]any) {
+		decoder := json.NewDecoder(buffer)
+		for decoder.More() {
+			var record map[string]any
+			_ = decoder.Decode(&record)
+			records = append(records, record)
+		}
+		return records
+	}
+}
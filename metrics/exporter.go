@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"bytes"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/smarty/ip-filter"
+)
+
+// Exporter publishes metrics for the filters it instruments, in the Prometheus text exposition format (as an
+// http.Handler) and through expvar.
+type Exporter struct {
+	lock    sync.Mutex
+	filters []*Instrumented
+}
+
+func New() *Exporter {
+	return &Exporter{}
+}
+
+// Instrument wraps filter so that its lookups are counted; use the returned filter in place of the original.
+func (this *Exporter) Instrument(name string, filter ipfilter.Filter) *Instrumented {
+	instrumented := &Instrumented{name: name, filter: filter}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.filters = append(this.filters, instrumented)
+
+	return instrumented
+}
+
+// Snapshot returns the current metrics of every instrumented filter in registration order.
+func (this *Exporter) Snapshot() []Snapshot {
+	this.lock.Lock()
+	filters := append([]*Instrumented{}, this.filters...)
+	this.lock.Unlock()
+
+	snapshots := make([]Snapshot, 0, len(filters))
+	for _, filter := range filters {
+		snapshots = append(snapshots, filter.snapshot())
+	}
+	return snapshots
+}
+
+// Publish registers the snapshots under name in expvar (served at /debug/vars). Like expvar.Publish, it panics
+// when name is already published.
+func (this *Exporter) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		snapshots := make(map[string]Snapshot)
+		for _, snapshot := range this.Snapshot() {
+			snapshots[snapshot.Name] = snapshot
+		}
+		return snapshots
+	}))
+}
+
+func (this *Exporter) ServeHTTP(response http.ResponseWriter, _ *http.Request) {
+	response.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = this.WriteTo(response)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (this *Exporter) WriteTo(writer io.Writer) (int64, error) {
+	snapshots := this.Snapshot()
+	buffer := &bytes.Buffer{}
+
+	writeFamily(buffer, "lookups_total", "counter", "Number of lookups.", snapshots,
+		func(snapshot Snapshot, write sampleWriter) { write("", snapshot.Lookups) })
+	writeFamily(buffer, "matches_total", "counter", "Number of lookups that matched a rule.", snapshots,
+		func(snapshot Snapshot, write sampleWriter) { write("", snapshot.Matches) })
+	writeFamily(buffer, "rules", "gauge", "Number of rules by address family.", snapshots,
+		func(snapshot Snapshot, write sampleWriter) {
+			if snapshot.Stats != nil {
+				write(`family="ipv4"`, snapshot.Stats.IPv4Rules)
+				write(`family="ipv6"`, snapshot.Stats.IPv6Rules)
+			}
+		})
+	writeFamily(buffer, "nodes", "gauge", "Number of trie nodes (or tables or intervals).", snapshots,
+		func(snapshot Snapshot, write sampleWriter) {
+			if snapshot.Stats != nil {
+				write("", snapshot.Stats.Nodes)
+			}
+		})
+	writeFamily(buffer, "memory_bytes", "gauge", "Estimated memory held by the filter.", snapshots,
+		func(snapshot Snapshot, write sampleWriter) {
+			if snapshot.Stats != nil {
+				write("", snapshot.Stats.Bytes)
+			}
+		})
+	writeFamily(buffer, "reload_generation", "counter", "Number of successful reloads.", snapshots,
+		func(snapshot Snapshot, write sampleWriter) {
+			if snapshot.Generation != nil {
+				write("", *snapshot.Generation)
+			}
+		})
+	writeFamily(buffer, "reload_timestamp_seconds", "gauge", "Unix time of the last successful reload.", snapshots,
+		func(snapshot Snapshot, write sampleWriter) {
+			if snapshot.Reloaded != nil && !snapshot.Reloaded.IsZero() {
+				write("", float64(snapshot.Reloaded.UnixNano())/1e9)
+			}
+		})
+
+	return buffer.WriteTo(writer)
+}
+
+type sampleWriter func(labels string, value any)
+
+func writeFamily(
+	buffer *bytes.Buffer, name, kind, help string, snapshots []Snapshot, samples func(Snapshot, sampleWriter)) {
+	var body bytes.Buffer
+	for _, snapshot := range snapshots {
+		samples(snapshot, func(labels string, value any) {
+			if len(labels) > 0 {
+				labels = "," + labels
+			}
+			_, _ = fmt.Fprintf(&body, "%s%s{filter=\"%s\"%s} %v\n",
+				metricPrefix, name, escapeLabel(snapshot.Name), labels, value)
+		})
+	}
+
+	if body.Len() == 0 {
+		return
+	}
+
+	_, _ = fmt.Fprintf(buffer, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricPrefix, name, help, metricPrefix, name, kind)
+	_, _ = body.WriteTo(buffer)
+}
+
+// escapeLabel escapes a label value the way the text exposition format defines: backslash, double quote and line
+// feed are the only escapes; every other character, including invalid UTF-8, is written as is.
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+const metricPrefix = "ipfilter_"
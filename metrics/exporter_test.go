@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smarty/ip-filter"
+)
+
+func TestInstrumentedCountsLookupsAndMatches(t *testing.T) {
+	exporter := New()
+	filter := exporter.Instrument("blocklist", ipfilter.New("10.0.0.0/8"))
+
+	Assert(t).That(filter.Contains("10.1.2.3")).Equals(true)
+	Assert(t).That(filter.Contains("11.1.2.3")).Equals(false)
+	Assert(t).That(ipfilter.ContainsAddr(filter, netip.MustParseAddr("10.1.2.3"))).Equals(true)
+	filter.Observe(true)
+	filter.Observe(false)
+
+	snapshot := exporter.Snapshot()[0]
+	Assert(t).That([]uint64{snapshot.Lookups, snapshot.Matches}).Equals([]uint64{5, 3})
+}
+func TestPrometheusExposition(t *testing.T) {
+	exporter := New()
+	blocklist := exporter.Instrument("blocklist", ipfilter.NewCompressed("10.0.0.0/8", "2600:f0f0:2::/48"))
+	reloaded := exporter.Instrument(`with "quotes"`, &reloadingFilter{Filter: ipfilter.New("10.0.0.0/8")})
+	exporter.Instrument("plain", foreignFilter{})
+	blocklist.Contains("10.1.2.3")
+	reloaded.Contains("11.1.2.3")
+
+	response := httptest.NewRecorder()
+	exporter.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	Assert(t).That(response.Header().Get("Content-Type")).Equals("text/plain; version=0.0.4; charset=utf-8")
+
+	samples, types := parseExposition(t, response.Body.String())
+	Assert(t).That(samples[`ipfilter_lookups_total{filter="blocklist"}`]).Equals(1.0)
+	Assert(t).That(samples[`ipfilter_matches_total{filter="blocklist"}`]).Equals(1.0)
+	Assert(t).That(samples[`ipfilter_lookups_total{filter="with \"quotes\""}`]).Equals(1.0)
+	Assert(t).That(samples[`ipfilter_matches_total{filter="plain"}`]).Equals(0.0)
+	Assert(t).That(samples[`ipfilter_rules{filter="blocklist",family="ipv4"}`]).Equals(1.0)
+	Assert(t).That(samples[`ipfilter_rules{filter="blocklist",family="ipv6"}`]).Equals(1.0)
+	Assert(t).That(samples[`ipfilter_nodes{filter="blocklist"}`]).Equals(2.0)
+	Assert(t).That(samples[`ipfilter_memory_bytes{filter="blocklist"}`] > 0).Equals(true)
+	Assert(t).That(samples[`ipfilter_reload_generation{filter="with \"quotes\""}`]).Equals(7.0)
+	Assert(t).That(samples[`ipfilter_reload_timestamp_seconds{filter="with \"quotes\""}`]).Equals(1700000000.5)
+	Assert(t).That(types).Equals(map[string]string{
+		"ipfilter_lookups_total":            "counter",
+		"ipfilter_matches_total":            "counter",
+		"ipfilter_rules":                    "gauge",
+		"ipfilter_nodes":                    "gauge",
+		"ipfilter_memory_bytes":             "gauge",
+		"ipfilter_reload_generation":        "counter",
+		"ipfilter_reload_timestamp_seconds": "gauge",
+	})
+	_, hasPlainRules := samples[`ipfilter_nodes{filter="plain"}`]
+	Assert(t).That(hasPlainRules).Equals(false)
+}
+func TestPrometheusLabelEscaping(t *testing.T) {
+	exporter := New()
+	exporter.Instrument("soft\u00adhyphen \x7f\xff \\ \"line\"\nbreak", foreignFilter{})
+
+	var builder strings.Builder
+	_, _ = exporter.WriteTo(&builder)
+
+	expected := "ipfilter_lookups_total{filter=\"soft\u00adhyphen \x7f\xff \\\\ \\\"line\\\"\\nbreak\"} 0\n"
+	Assert(t).That(strings.Contains(builder.String(), expected)).Equals(true)
+}
+func TestEmptyExporterWritesNothing(t *testing.T) {
+	var builder strings.Builder
+	written, err := New().WriteTo(&builder)
+	Assert(t).That(written).Equals(int64(0))
+	Assert(t).That(err).Equals(nil)
+}
+func TestExpvar(t *testing.T) {
+	exporter := New()
+	exporter.Instrument("blocklist", ipfilter.New("10.0.0.0/8")).Contains("10.1.2.3")
+	exporter.Publish("ipfilter_test")
+
+	var published map[string]struct {
+		Lookups uint64
+		Matches uint64
+		Stats   ipfilter.Stats
+	}
+	Assert(t).That(json.Unmarshal([]byte(expvar.Get("ipfilter_test").String()), &published)).Equals(nil)
+	Assert(t).That(published["blocklist"].Lookups).Equals(uint64(1))
+	Assert(t).That(published["blocklist"].Matches).Equals(uint64(1))
+	Assert(t).That(published["blocklist"].Stats.IPv4Rules).Equals(1)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// parseExposition returns the samples by metric name and labels, and the declared type of each family, failing on
+// lines that are not valid in the text format.
+func parseExposition(t *testing.T, text string) (map[string]float64, map[string]string) {
+	samples, types := make(map[string]float64), make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if fields := strings.Fields(line); strings.HasPrefix(line, "# TYPE ") && len(fields) == 4 {
+			types[fields[2]] = fields[3]
+			continue
+		} else if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+
+		index := strings.LastIndex(line, " ")
+		value, err := strconv.ParseFloat(line[index+1:], 64)
+		if index < 0 || err != nil || !strings.HasSuffix(line[:index], "}") {
+			t.Fatalf("invalid sample: %q", line)
+		}
+		if _, declared := types[line[:strings.Index(line, "{")]]; !declared {
+			t.Fatalf("sample before its TYPE: %q", line)
+		}
+		samples[line[:index]] = value
+	}
+
+	return samples, types
+}
+
+type reloadingFilter struct{ ipfilter.Filter }
+
+func (this *reloadingFilter) Generation() (uint64, time.Time) {
+	return 7, time.Unix(1700000000, 500000000)
+}
+
+type foreignFilter struct{}
+
+func (foreignFilter) Contains(string) bool { return false }
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type That struct{ t *testing.T }
+type Assertion struct {
+	*testing.T
+	actual interface{}
+}
+
+func Assert(t *testing.T) *That                       { return &That{t: t} }
+func (this *That) That(actual interface{}) *Assertion { return &Assertion{T: this.t, actual: actual} }
+
+func (this *Assertion) Equals(expected interface{}) {
+	this.Helper()
+	if !reflect.DeepEqual(this.actual, expected) {
+		this.Errorf("\nExpected: %#v\nActual:   %#v", expected, this.actual)
+	}
+}
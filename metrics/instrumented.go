@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"net/netip"
+	"sync/atomic"
+	"time"
+
+	"github.com/smarty/ip-filter"
+)
+
+// Instrumented counts the lookups and matches of the filter it wraps.
+type Instrumented struct {
+	name    string
+	filter  ipfilter.Filter
+	lookups atomic.Uint64
+	matches atomic.Uint64
+}
+
+func (this *Instrumented) Contains(ipAddress string) bool {
+	return this.count(this.filter.Contains(ipAddress))
+}
+func (this *Instrumented) ContainsAddr(address netip.Addr) bool {
+	return this.count(ipfilter.ContainsAddr(this.filter, address))
+}
+
+// Observe counts a lookup that was answered without going through Contains, such as by a richer evaluation of the
+// same rules.
+func (this *Instrumented) Observe(matched bool) { this.count(matched) }
+func (this *Instrumented) count(matched bool) bool {
+	this.lookups.Add(1)
+	if matched {
+		this.matches.Add(1)
+	}
+	return matched
+}
+
+func (this *Instrumented) snapshot() Snapshot {
+	snapshot := Snapshot{Name: this.name, Lookups: this.lookups.Load(), Matches: this.matches.Load()}
+
+	if measurer, ok := this.filter.(interface{ Stats() ipfilter.Stats }); ok {
+		stats := measurer.Stats()
+		snapshot.Stats = &stats
+	}
+
+	if reloader, ok := this.filter.(interface{ Generation() (uint64, time.Time) }); ok {
+		generation, reloaded := reloader.Generation()
+		snapshot.Generation = &generation
+		snapshot.Reloaded = &reloaded
+	}
+
+	return snapshot
+}
+
+// Snapshot holds the metrics of one instrumented filter. Stats are only present for filters with a Stats method;
+// Generation and Reloaded only for filters with a Generation method (such as reloaders).
+type Snapshot struct {
+	Name       string
+	Lookups    uint64
+	Matches    uint64
+	Stats      *ipfilter.Stats `json:",omitempty"`
+	Generation *uint64         `json:",omitempty"`
+	Reloaded   *time.Time      `json:",omitempty"`
+}
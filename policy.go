@@ -0,0 +1,238 @@
+package ipfilter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// Policy maps prefixes to actions, like a firewall rule set. Of the rules containing an address, the one with the
+// highest priority decides; ties go to the longest prefix, then to the rule added first. Without priorities this is
+// plain longest-prefix matching. Addresses no rule contains get the default action.
+//
+// A Policy is built once and then only read; Add must not be called concurrently with lookups.
+type Policy struct {
+	rules    valueTrie[[]PolicyRule]
+	fallback Action
+}
+
+type PolicyRule struct {
+	Name     string // optional, for reporting
+	Prefix   netip.Prefix
+	Action   Action
+	Priority int
+}
+
+// Decision is the outcome of Policy.Evaluate.
+type Decision struct {
+	Action Action
+	Rule   *PolicyRule // nil when no rule matched and the default action applies
+}
+
+func NewPolicy(fallback Action, rules ...PolicyRule) (*Policy, error) {
+	this := &Policy{fallback: fallback}
+
+	for _, rule := range rules {
+		if err := this.Add(rule); err != nil {
+			return nil, err
+		}
+	}
+
+	return this, nil
+}
+
+// Add appends a rule; the prefix is masked, and IPv6 prefixes keep only their first 64 bits.
+func (this *Policy) Add(rule PolicyRule) error {
+	family, numericIP, subnetBits, ok := parsePrefix(rule.Prefix)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrInvalidPolicyPrefix, rule.Prefix)
+	}
+	if rule.Action < Allow || rule.Action > Log {
+		return fmt.Errorf("%w: %d", ErrUnknownAction, rule.Action)
+	}
+
+	rule.Prefix = formatPrefix(family, numericIP, subnetBits)
+	rules, _ := this.rules.insert(family, numericIP, subnetBits)
+	*rules = append(*rules, rule)
+	return nil
+}
+
+func (this *Policy) Evaluate(address netip.Addr) Decision {
+	family, numericIP, ok := parseAddr(address)
+	if !ok {
+		return Decision{Action: this.fallback}
+	}
+
+	return this.evaluate(family, numericIP)
+}
+func (this *Policy) EvaluateString(ipAddress string) Decision {
+	family, numericIP, ok := parseAddress(ipAddress)
+	if !ok {
+		return Decision{Action: this.fallback}
+	}
+
+	return this.evaluate(family, numericIP)
+}
+func (this *Policy) evaluate(family int, numericIP uint64) Decision {
+	var decided *PolicyRule
+
+	// prefixes are visited shortest first, so a later prefix with the same priority is longer and wins
+	this.rules.walk(family, numericIP, func(_ int, rules *[]PolicyRule) bool {
+		best := &(*rules)[0]
+		for i := range *rules {
+			if (*rules)[i].Priority > best.Priority {
+				best = &(*rules)[i]
+			}
+		}
+
+		if decided == nil || best.Priority >= decided.Priority {
+			decided = best
+		}
+		return true
+	})
+
+	if decided == nil {
+		return Decision{Action: this.fallback}
+	}
+
+	rule := *decided
+	return Decision{Action: rule.Action, Rule: &rule}
+}
+
+// Contains reports whether the policy denies the address, so a Policy can be used wherever a Filter is expected.
+func (this *Policy) Contains(ipAddress string) bool {
+	return this.EvaluateString(ipAddress).Action == Deny
+}
+func (this *Policy) ContainsAddr(address netip.Addr) bool {
+	return this.Evaluate(address).Action == Deny
+}
+
+// Default returns the action for addresses no rule contains.
+func (this *Policy) Default() Action { return this.fallback }
+
+// Rules returns every rule in address order, shorter prefixes before the prefixes they contain.
+func (this *Policy) Rules() (rules []PolicyRule) {
+	this.rules.each(func(_ int, _ uint64, _ int, items *[]PolicyRule) { rules = append(rules, *items...) })
+	return rules
+}
+
+func (this *Policy) Stats() Stats {
+	stats := this.rules.stats(int(unsafe.Sizeof(valueNode[[]PolicyRule]{})))
+	this.rules.each(func(_ int, _ uint64, _ int, items *[]PolicyRule) {
+		stats.Bytes += cap(*items) * int(unsafe.Sizeof(PolicyRule{}))
+	})
+	return stats
+}
+
+// ParsePolicy reads a policy file with one statement per line; blank lines and everything after a '#' are ignored:
+//
+//	default allow
+//	allow 192.0.2.0/24 name=office
+//	log   3.0.0.0/9    name=cloud
+//	deny  10.0.0.0/8   name=tor priority=10
+//
+// The default action is allow unless a default statement says otherwise. Errors carry the line number.
+func ParsePolicy(reader io.Reader) (*Policy, error) {
+	this := &Policy{fallback: Allow}
+	scanner := bufio.NewScanner(reader)
+
+	for number := 1; scanner.Scan(); number++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if err := this.parseStatement(fields); err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return this, nil
+}
+func (this *Policy) parseStatement(fields []string) (err error) {
+	if fields[0] == "default" {
+		if len(fields) != 2 {
+			return fmt.Errorf("%w: expected \"default <action>\"", ErrMalformedPolicy)
+		}
+		this.fallback, err = ParseAction(fields[1])
+		return err
+	}
+
+	if len(fields) < 2 {
+		return fmt.Errorf("%w: expected \"<action> <prefix> [name=...] [priority=...]\"", ErrMalformedPolicy)
+	}
+
+	var rule PolicyRule
+	if rule.Action, err = ParseAction(fields[0]); err != nil {
+		return err
+	}
+	if rule.Prefix, err = ParseRule(fields[1]); err != nil {
+		return fmt.Errorf("%w: %q", err, fields[1])
+	}
+
+	for _, field := range fields[2:] {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "name":
+			rule.Name = value
+		case "priority":
+			if rule.Priority, err = strconv.Atoi(value); err != nil {
+				return fmt.Errorf("%w: invalid priority %q", ErrMalformedPolicy, value)
+			}
+		default:
+			return fmt.Errorf("%w: unknown attribute %q", ErrMalformedPolicy, field)
+		}
+	}
+
+	return this.Add(rule)
+}
+
+type Action int
+
+const (
+	Allow Action = iota
+	Deny
+	Log // allow, but record the request
+)
+
+func (this Action) String() string {
+	switch this {
+	case Allow:
+		return "allow"
+	case Deny:
+		return "deny"
+	case Log:
+		return "log"
+	default:
+		return "action(" + strconv.Itoa(int(this)) + ")"
+	}
+}
+
+func ParseAction(value string) (Action, error) {
+	switch strings.ToLower(value) {
+	case "allow":
+		return Allow, nil
+	case "deny":
+		return Deny, nil
+	case "log":
+		return Log, nil
+	default:
+		return Allow, fmt.Errorf("%w: %q", ErrUnknownAction, value)
+	}
+}
+
+var (
+	ErrUnknownAction       = errors.New("unknown policy action")
+	ErrInvalidPolicyPrefix = errors.New("invalid policy prefix")
+	ErrMalformedPolicy     = errors.New("malformed policy statement")
+)
@@ -0,0 +1,128 @@
+package ipfilter
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestPolicyLongestPrefixDecides(t *testing.T) {
+	policy, err := NewPolicy(Allow,
+		PolicyRule{Name: "cloud", Prefix: netip.MustParsePrefix("10.0.0.0/8"), Action: Log},
+		PolicyRule{Name: "tor", Prefix: netip.MustParsePrefix("10.1.0.0/16"), Action: Deny},
+		PolicyRule{Name: "office", Prefix: netip.MustParsePrefix("10.1.2.0/24"), Action: Allow},
+		PolicyRule{Name: "v6", Prefix: netip.MustParsePrefix("2600:f0f0:2::/48"), Action: Deny},
+	)
+	Assert(t).That(err).Equals(nil)
+
+	assertDecision(t, policy, "10.1.2.3", Allow, "office")
+	assertDecision(t, policy, "10.1.9.9", Deny, "tor")
+	assertDecision(t, policy, "10.9.9.9", Log, "cloud")
+	assertDecision(t, policy, "2600:f0f0:2::1", Deny, "v6")
+	assertDecision(t, policy, "11.1.2.3", Allow, "")
+	assertDecision(t, policy, "not an address", Allow, "")
+	Assert(t).That(policy.Evaluate(netip.Addr{})).Equals(Decision{Action: Allow})
+
+	assertContains(t, policy, "10.1.9.9", "2600:f0f0:2::1")
+	assertNotContains(t, policy, "10.1.2.3", "10.9.9.9", "11.1.2.3")
+	Assert(t).That(policy.ContainsAddr(netip.MustParseAddr("10.1.9.9"))).Equals(true)
+	Assert(t).That(policy.Stats().Rules()).Equals(4)
+}
+func TestPolicyPriorityOverridesPrefixLength(t *testing.T) {
+	policy, _ := NewPolicy(Deny,
+		PolicyRule{Name: "tor", Prefix: netip.MustParsePrefix("10.0.0.0/8"), Action: Deny, Priority: 10},
+		PolicyRule{Name: "office", Prefix: netip.MustParsePrefix("10.1.2.0/24"), Action: Allow},
+		PolicyRule{Name: "first", Prefix: netip.MustParsePrefix("11.0.0.0/8"), Action: Log},
+		PolicyRule{Name: "second", Prefix: netip.MustParsePrefix("11.0.0.0/8"), Action: Allow},
+		PolicyRule{Name: "low", Prefix: netip.MustParsePrefix("12.0.0.0/8"), Action: Log, Priority: -1},
+		PolicyRule{Name: "high", Prefix: netip.MustParsePrefix("12.0.0.0/8"), Action: Allow, Priority: 5},
+		PolicyRule{Name: "nested", Prefix: netip.MustParsePrefix("12.1.0.0/16"), Action: Log, Priority: 5},
+	)
+
+	assertDecision(t, policy, "10.1.2.3", Deny, "tor")
+	assertDecision(t, policy, "11.1.2.3", Log, "first") // same prefix and priority: first added
+	assertDecision(t, policy, "12.9.9.9", Allow, "high")
+	assertDecision(t, policy, "12.1.2.3", Log, "nested") // same priority: longer prefix
+	assertDecision(t, policy, "13.1.2.3", Deny, "")
+	Assert(t).That(policy.Default()).Equals(Deny)
+}
+func TestPolicyRejectsInvalidRules(t *testing.T) {
+	_, err := NewPolicy(Allow, PolicyRule{Prefix: netip.MustParsePrefix("0.0.0.0/0"), Action: Deny})
+	Assert(t).That(errors.Is(err, ErrInvalidPolicyPrefix)).Equals(true)
+
+	_, err = NewPolicy(Allow, PolicyRule{Action: Deny})
+	Assert(t).That(errors.Is(err, ErrInvalidPolicyPrefix)).Equals(true)
+
+	_, err = NewPolicy(Allow, PolicyRule{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Action: Action(7)})
+	Assert(t).That(err.Error()).Equals("unknown policy action: 7")
+}
+func TestPolicyDefaultAppliesThroughPackageLookups(t *testing.T) {
+	policy, _ := NewPolicy(Deny, PolicyRule{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Action: Allow})
+
+	// the trie cannot hold ::/64 or 0.0.0.0, so these addresses reach the default action
+	addresses := []netip.Addr{netip.MustParseAddr("::1"), netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("10.1.2.3")}
+	for i, expected := range []bool{true, true, false} {
+		Assert(t).That(ContainsAddr(policy, addresses[i])).Equals(expected)
+	}
+
+	out := make([]bool, len(addresses))
+	ContainsBatch(policy, addresses, out)
+	Assert(t).That(out).Equals([]bool{true, true, false})
+
+	ContainsStringBatch(policy, []string{"::1", "0.0.0.0", "10.1.2.3"}, out)
+	Assert(t).That(out).Equals([]bool{true, true, false})
+}
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy(strings.NewReader(`
+# office first
+default deny
+allow 192.0.2.7/24   name=office
+log   3.0.0.0/9      name=cloud   # inline comment
+DENY  2600:f0f0:2::/48 priority=10
+`))
+	Assert(t).That(err).Equals(nil)
+	Assert(t).That(policy.Default()).Equals(Deny)
+	Assert(t).That(policy.Rules()).Equals([]PolicyRule{
+		{Name: "cloud", Prefix: netip.MustParsePrefix("3.0.0.0/9"), Action: Log},
+		{Name: "office", Prefix: netip.MustParsePrefix("192.0.2.0/24"), Action: Allow},
+		{Prefix: netip.MustParsePrefix("2600:f0f0:2::/48"), Action: Deny, Priority: 10},
+	})
+	assertDecision(t, policy, "192.0.2.1", Allow, "office")
+	assertDecision(t, policy, "8.8.8.8", Deny, "")
+
+	for text, expected := range map[string]string{
+		"default":                        `line 1: malformed policy statement: expected "default <action>"`,
+		"default block":                  `line 1: unknown policy action: "block"`,
+		"\n\ndeny":                       `line 3: malformed policy statement: expected "<action> <prefix> [name=...] [priority=...]"`,
+		"block 10.0.0.0/8":               `line 1: unknown policy action: "block"`,
+		"deny 10.0.0.0/33":               `line 1: invalid subnet bits: "10.0.0.0/33"`,
+		"deny 10.0.0.0/8 priority=high":  `line 1: malformed policy statement: invalid priority "high"`,
+		"deny 10.0.0.0/8 color=red":      `line 1: malformed policy statement: unknown attribute "color=red"`,
+		"allow 10.0.0.0/8\ndeny 1.2.3.4": `line 2: missing subnet bits (expected address/bits): "1.2.3.4"`,
+	} {
+		_, err := ParsePolicy(strings.NewReader(text))
+		Assert(t).That(err.Error()).Equals(expected)
+	}
+}
+func TestActionText(t *testing.T) {
+	for _, action := range []Action{Allow, Deny, Log} {
+		parsed, err := ParseAction(action.String())
+		Assert(t).That(parsed).Equals(action)
+		Assert(t).That(err).Equals(nil)
+	}
+	Assert(t).That(Action(7).String()).Equals("action(7)")
+}
+
+func assertDecision(t *testing.T, policy *Policy, address string, action Action, name string) {
+	t.Helper()
+	decision := policy.EvaluateString(address)
+	Assert(t).That(decision.Action).Equals(action)
+	if len(name) == 0 {
+		Assert(t).That(decision.Rule == nil).Equals(true)
+	} else if decision.Rule == nil {
+		t.Errorf("expected rule %s for %s", name, address)
+	} else {
+		Assert(t).That(decision.Rule.Name).Equals(name)
+	}
+}
@@ -0,0 +1,274 @@
+package ipfilter
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// PortFilter matches connections by address, destination port and protocol, for rules that only apply to some
+// services. The address is looked up in a prefix trie as in the other filters; only the prefixes containing it have
+// their port ranges checked, so a lookup costs little more than Contains.
+//
+// A PortFilter is built once and then only read; Add must not be called concurrently with lookups.
+type PortFilter struct {
+	rules valueTrie[[]portRange]
+}
+type portRange struct {
+	first, last uint16
+	protocol    Protocol
+}
+
+// PortRule is a prefix limited to a protocol and a range of destination ports. AnyProtocol matches every protocol,
+// and the range 0-65535 matches every port, as does the zero range (0-0): port 0 is never a destination, so
+// PortRule{Prefix: prefix} is a plain rule, like a prefix without a service in the text syntax.
+type PortRule struct {
+	Prefix    netip.Prefix
+	Protocol  Protocol
+	FirstPort uint16
+	LastPort  uint16
+}
+
+// NewPortFilter builds a filter from rules in the syntax of ParsePortRule, skipping rules that do not parse.
+func NewPortFilter(rules ...string) *PortFilter {
+	this := &PortFilter{}
+
+	for _, item := range rules {
+		if rule, err := ParsePortRule(item); err == nil {
+			_ = this.Add(rule)
+		}
+	}
+
+	return this
+}
+
+// Add appends a port rule to those of its prefix. Only the address part is masked (IPv6 to at most 64 bits, as in the
+// trie); the protocol and ports are kept as given, except that the zero range is stored as 0-65535. Rules for the
+// same prefix are kept in the order they were added, duplicates included.
+func (this *PortFilter) Add(rule PortRule) error {
+	family, numericIP, subnetBits, ok := parsePrefix(rule.Prefix)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrInvalidAddress, rule.Prefix)
+	}
+	if rule.Protocol > UDP {
+		return fmt.Errorf("%w: %s", ErrUnknownProtocol, rule.Protocol)
+	}
+	if rule.FirstPort > rule.LastPort {
+		return fmt.Errorf("%w: %d-%d", ErrInvalidPorts, rule.FirstPort, rule.LastPort)
+	}
+
+	first, last := rule.ports()
+	ranges, _ := this.rules.insert(family, numericIP, subnetBits)
+	*ranges = append(*ranges, portRange{first: first, last: last, protocol: rule.Protocol})
+	return nil
+}
+
+// ports returns the range of the rule, with the zero range widened to every port.
+func (this PortRule) ports() (first, last uint16) {
+	if this.FirstPort == 0 && this.LastPort == 0 {
+		return 0, maximumPort
+	}
+
+	return this.FirstPort, this.LastPort
+}
+
+// Match reports whether a rule contains the address, the port and the protocol. Rules for AnyProtocol match every
+// protocol; a connection whose protocol is AnyProtocol (unknown) only matches those rules.
+func (this *PortFilter) Match(address netip.Addr, port uint16, protocol Protocol) bool {
+	family, numericIP, ok := parseAddr(address)
+	return ok && this.match(family, numericIP, port, protocol)
+}
+func (this *PortFilter) MatchString(ipAddress string, port uint16, protocol Protocol) bool {
+	family, numericIP, ok := parseAddress(ipAddress)
+	return ok && this.match(family, numericIP, port, protocol)
+}
+func (this *PortFilter) match(family int, numericIP uint64, port uint16, protocol Protocol) (matched bool) {
+	this.rules.walk(family, numericIP, func(_ int, ranges *[]portRange) bool {
+		for _, item := range *ranges {
+			if item.contains(port, protocol) {
+				matched = true
+				return false
+			}
+		}
+		return true
+	})
+	return matched
+}
+
+// Lookup returns the rule deciding a match: of the rules containing the address, the port and the protocol, the one
+// with the longest prefix, then the one added first.
+func (this *PortFilter) Lookup(address netip.Addr, port uint16, protocol Protocol) (rule PortRule, matched bool) {
+	family, numericIP, ok := parseAddr(address)
+	if !ok {
+		return PortRule{}, false
+	}
+
+	// prefixes are visited shortest first, so a later match is longer
+	this.rules.walk(family, numericIP, func(subnetBits int, ranges *[]portRange) bool {
+		for _, item := range *ranges {
+			if item.contains(port, protocol) {
+				rule, matched = item.rule(formatPrefix(family, numericIP, subnetBits)), true
+				break
+			}
+		}
+		return true
+	})
+
+	return rule, matched
+}
+func (this portRange) contains(port uint16, protocol Protocol) bool {
+	return (this.protocol == AnyProtocol || this.protocol == protocol) && this.first <= port && port <= this.last
+}
+func (this portRange) rule(prefix netip.Prefix) PortRule {
+	return PortRule{Prefix: prefix, Protocol: this.protocol, FirstPort: this.first, LastPort: this.last}
+}
+
+// Rules returns the port rules grouped by prefix, with prefixes in address order and each prefix before the longer
+// prefixes inside it; within a prefix, rules keep the order they were added. Prefixes come back masked and rules
+// added with the zero range come back as 0-65535, so a rule may not equal the PortRule passed to Add.
+func (this *PortFilter) Rules() (rules []PortRule) {
+	this.rules.each(func(family int, numericIP uint64, subnetBits int, ranges *[]portRange) {
+		prefix := formatPrefix(family, numericIP, subnetBits)
+		for _, item := range *ranges {
+			rules = append(rules, item.rule(prefix))
+		}
+	})
+	return rules
+}
+
+// Stats counts distinct prefixes; a prefix with several port ranges counts once.
+func (this *PortFilter) Stats() Stats {
+	stats := this.rules.stats(int(unsafe.Sizeof(valueNode[[]portRange]{})))
+	this.rules.each(func(_ int, _ uint64, _ int, ranges *[]portRange) {
+		stats.Bytes += cap(*ranges) * int(unsafe.Sizeof(portRange{}))
+	})
+	return stats
+}
+
+// ParsePortRule parses a prefix followed by an optional service, separated by white space:
+//
+//	10.0.0.0/8 tcp/22         one port of one protocol
+//	10.0.0.0/8 udp/5000-5100  a range of ports
+//	10.0.0.0/8 tcp            every port of one protocol
+//	10.0.0.0/8 443            one port of any protocol (also any/443)
+//	10.0.0.0/8                everything, like a plain rule
+//
+// The prefix is parsed like ParseRule.
+func ParsePortRule(rule string) (PortRule, error) {
+	fields := strings.Fields(rule)
+	if len(fields) == 0 {
+		return PortRule{}, ErrEmptyRule
+	}
+	if len(fields) > 2 {
+		return PortRule{}, fmt.Errorf("%w: %q", ErrMalformedPortRule, rule)
+	}
+
+	prefix, err := ParseRule(fields[0])
+	if err != nil {
+		return PortRule{}, fmt.Errorf("%w: %q", err, fields[0])
+	}
+
+	parsed := PortRule{Prefix: prefix, LastPort: maximumPort}
+	if len(fields) == 1 {
+		return parsed, nil
+	}
+
+	service := fields[1]
+	protocol, ports, found := strings.Cut(service, "/")
+	if !found && service[0] >= '0' && service[0] <= '9' {
+		protocol, ports = "", service
+	}
+
+	if len(protocol) > 0 {
+		if parsed.Protocol, err = ParseProtocol(protocol); err != nil {
+			return PortRule{}, err
+		}
+	}
+	if found || len(protocol) == 0 {
+		if parsed.FirstPort, parsed.LastPort, err = parsePorts(ports); err != nil {
+			return PortRule{}, err
+		}
+	}
+
+	return parsed, nil
+}
+func parsePorts(value string) (first, last uint16, err error) {
+	low, high, found := strings.Cut(value, "-")
+	if !found {
+		high = low
+	}
+
+	parsedLow, lowErr := strconv.ParseUint(low, decimalNumber, 16)
+	parsedHigh, highErr := strconv.ParseUint(high, decimalNumber, 16)
+	if lowErr != nil || highErr != nil || parsedLow > parsedHigh || parsedHigh == 0 { // 0-0 would mean every port
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidPorts, value)
+	}
+
+	return uint16(parsedLow), uint16(parsedHigh), nil
+}
+
+// String formats the rule in the syntax of ParsePortRule.
+func (this PortRule) String() string {
+	text := this.Prefix.String()
+	first, last := this.ports()
+
+	switch {
+	case first == 0 && last == maximumPort && this.Protocol == AnyProtocol:
+		return text
+	case first == 0 && last == maximumPort:
+		return text + " " + this.Protocol.String()
+	}
+
+	text += " " + this.Protocol.String() + "/" + strconv.Itoa(int(first))
+	if last != first {
+		text += "-" + strconv.Itoa(int(last))
+	}
+
+	return text
+}
+
+type Protocol uint8
+
+const (
+	AnyProtocol Protocol = iota
+	TCP
+	UDP
+)
+
+func (this Protocol) String() string {
+	switch this {
+	case AnyProtocol:
+		return "any"
+	case TCP:
+		return "tcp"
+	case UDP:
+		return "udp"
+	default:
+		return "protocol(" + strconv.Itoa(int(this)) + ")"
+	}
+}
+
+// ParseProtocol is the inverse of Protocol.String, ignoring case.
+func ParseProtocol(value string) (Protocol, error) {
+	switch strings.ToLower(value) {
+	case "any":
+		return AnyProtocol, nil
+	case "tcp":
+		return TCP, nil
+	case "udp":
+		return UDP, nil
+	default:
+		return AnyProtocol, fmt.Errorf("%w: %q", ErrUnknownProtocol, value)
+	}
+}
+
+var (
+	ErrUnknownProtocol   = errors.New("unknown protocol")
+	ErrInvalidPorts      = errors.New("invalid port range")
+	ErrMalformedPortRule = errors.New("malformed port rule (expected \"<prefix> [protocol][/port[-port]]\")")
+)
+
+const maximumPort = 1<<16 - 1
@@ -0,0 +1,33 @@
+package ipfilter
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func BenchmarkPortFilterMatch(b *testing.B) {
+	rules := make([]string, 0, len(ipAddresses))
+	for i, rule := range ipAddresses {
+		rules = append(rules, rule+[]string{" tcp/22", " udp/5000-5100", " 443", ""}[i%4])
+	}
+	filter := NewPortFilter(rules...)
+	address := netip.MustParseAddr("52.93.153.170")
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		_ = filter.Match(address, 443, TCP)
+	}
+}
+func BenchmarkPortFilterBaseline(b *testing.B) {
+	filter := NewCompressed(ipAddresses...)
+	address := netip.MustParseAddr("52.93.153.170")
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		_ = ContainsAddr(filter, address)
+	}
+}
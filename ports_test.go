@@ -0,0 +1,131 @@
+package ipfilter
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestPortFilterMatchesServices(t *testing.T) {
+	filter := NewPortFilter(
+		"10.0.0.0/8 tcp/22",
+		"10.0.0.0/8 udp/5000-5100",
+		"10.1.0.0/16 tcp",
+		"192.0.2.0/24 53",
+		"198.51.100.0/24",
+		"2600:f0f0:2::/48 tcp/443",
+		"10.0.0.0/8 icmp", // skipped
+	)
+
+	assertPortMatch(t, filter, "10.9.9.9", 22, TCP, true)
+	assertPortMatch(t, filter, "10.9.9.9", 22, UDP, false)
+	assertPortMatch(t, filter, "10.9.9.9", 443, TCP, false)
+	assertPortMatch(t, filter, "10.9.9.9", 5000, UDP, true)
+	assertPortMatch(t, filter, "10.9.9.9", 5100, UDP, true)
+	assertPortMatch(t, filter, "10.9.9.9", 5101, UDP, false)
+	assertPortMatch(t, filter, "10.1.9.9", 443, TCP, true)
+	assertPortMatch(t, filter, "10.1.9.9", 443, UDP, false)
+	assertPortMatch(t, filter, "192.0.2.1", 53, UDP, true)
+	assertPortMatch(t, filter, "192.0.2.1", 53, AnyProtocol, true)
+	assertPortMatch(t, filter, "192.0.2.1", 54, UDP, false)
+	assertPortMatch(t, filter, "198.51.100.1", 0, AnyProtocol, true)
+	assertPortMatch(t, filter, "198.51.100.1", 65535, TCP, true)
+	assertPortMatch(t, filter, "2600:f0f0:2::1", 443, TCP, true)
+	assertPortMatch(t, filter, "2600:f0f0:2::1", 80, TCP, false)
+	assertPortMatch(t, filter, "11.1.2.3", 22, TCP, false)
+	assertPortMatch(t, filter, "10.9.9.9", 22, AnyProtocol, false) // an unknown protocol only matches rules for any
+
+	Assert(t).That(filter.MatchString("not an address", 22, TCP)).Equals(false)
+	Assert(t).That(filter.Match(netip.Addr{}, 22, TCP)).Equals(false)
+	Assert(t).That(filter.Stats().Rules()).Equals(5) // prefixes
+}
+func TestPortFilterLookupReturnsTheMostSpecificRule(t *testing.T) {
+	filter := NewPortFilter("10.0.0.0/8 tcp/22", "10.1.0.0/16 tcp/1-1024", "10.1.0.0/16 any/22")
+
+	rule, matched := filter.Lookup(netip.MustParseAddr("10.1.2.3"), 22, TCP)
+	Assert(t).That(matched).Equals(true)
+	Assert(t).That(rule.String()).Equals("10.1.0.0/16 tcp/1-1024") // same prefix: added first
+
+	rule, _ = filter.Lookup(netip.MustParseAddr("10.9.2.3"), 22, TCP)
+	Assert(t).That(rule).Equals(PortRule{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Protocol: TCP, FirstPort: 22, LastPort: 22})
+
+	rule, matched = filter.Lookup(netip.MustParseAddr("10.9.2.3"), 23, TCP)
+	Assert(t).That(matched).Equals(false)
+	Assert(t).That(rule).Equals(PortRule{})
+}
+func TestPortFilterAdd(t *testing.T) {
+	filter := NewPortFilter()
+
+	Assert(t).That(filter.Add(PortRule{Prefix: netip.MustParsePrefix("10.1.2.3/16"), Protocol: UDP, LastPort: 53})).Equals(nil)
+	Assert(t).That(filter.Rules()).Equals([]PortRule{{Prefix: netip.MustParsePrefix("10.1.0.0/16"), Protocol: UDP, LastPort: 53}})
+
+	err := filter.Add(PortRule{Prefix: netip.MustParsePrefix("0.0.0.0/0")})
+	Assert(t).That(errors.Is(err, ErrInvalidAddress)).Equals(true)
+	err = filter.Add(PortRule{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Protocol: Protocol(9)})
+	Assert(t).That(err.Error()).Equals("unknown protocol: protocol(9)")
+	err = filter.Add(PortRule{Prefix: netip.MustParsePrefix("10.0.0.0/8"), FirstPort: 80, LastPort: 79})
+	Assert(t).That(err.Error()).Equals("invalid port range: 80-79")
+}
+func TestPortRuleZeroRangeMatchesEveryPort(t *testing.T) {
+	filter := NewPortFilter()
+	Assert(t).That(filter.Add(PortRule{Prefix: netip.MustParsePrefix("10.0.0.0/8")})).Equals(nil)
+	Assert(t).That(filter.Add(PortRule{Prefix: netip.MustParsePrefix("11.0.0.0/8"), Protocol: TCP})).Equals(nil)
+
+	assertPortMatch(t, filter, "10.1.2.3", 0, AnyProtocol, true)
+	assertPortMatch(t, filter, "10.1.2.3", 443, UDP, true)
+	assertPortMatch(t, filter, "10.1.2.3", 65535, TCP, true)
+	assertPortMatch(t, filter, "11.1.2.3", 22, TCP, true)
+	assertPortMatch(t, filter, "11.1.2.3", 22, UDP, false)
+
+	parsed, _ := ParsePortRule("10.0.0.0/8")
+	Assert(t).That(filter.Rules()[0]).Equals(parsed)
+	Assert(t).That(PortRule{Prefix: netip.MustParsePrefix("11.0.0.0/8"), Protocol: TCP}.String()).Equals("11.0.0.0/8 tcp")
+}
+func TestParsePortRule(t *testing.T) {
+	for text, expected := range map[string]string{
+		"10.0.0.0/8 tcp/22":        "10.0.0.0/8 tcp/22",
+		"10.1.2.3/8\tUDP/53-54":    "10.0.0.0/8 udp/53-54",
+		"10.0.0.0/8 tcp":           "10.0.0.0/8 tcp",
+		"10.0.0.0/8 443":           "10.0.0.0/8 any/443",
+		"10.0.0.0/8 any/0-65535":   "10.0.0.0/8",
+		"  10.0.0.0/8  ":           "10.0.0.0/8",
+		"2600:f0f0:2::/48 tcp/443": "2600:f0f0:2::/48 tcp/443",
+	} {
+		rule, err := ParsePortRule(text)
+		Assert(t).That(err).Equals(nil)
+		Assert(t).That(rule.String()).Equals(expected)
+	}
+
+	for text, expected := range map[string]string{
+		"":                      "empty rule",
+		"10.0.0.0/8 tcp 22":     `malformed port rule (expected "<prefix> [protocol][/port[-port]]"): "10.0.0.0/8 tcp 22"`,
+		"10.0.0.0/33 tcp/22":    `invalid subnet bits: "10.0.0.0/33"`,
+		"10.0.0.0 tcp/22":       `missing subnet bits (expected address/bits): "10.0.0.0"`,
+		"10.0.0.0/8 icmp":       `unknown protocol: "icmp"`,
+		"10.0.0.0/8 tcp/":       `invalid port range: ""`,
+		"10.0.0.0/8 tcp/65536":  `invalid port range: "65536"`,
+		"10.0.0.0/8 udp/100-99": `invalid port range: "100-99"`,
+		"10.0.0.0/8 22-ssh":     `invalid port range: "22-ssh"`,
+		"10.0.0.0/8 tcp/http":   `invalid port range: "http"`,
+		"10.0.0.0/8 tcp/0":      `invalid port range: "0"`,
+	} {
+		_, err := ParsePortRule(text)
+		Assert(t).That(err.Error()).Equals(expected)
+	}
+}
+func TestProtocolText(t *testing.T) {
+	for _, protocol := range []Protocol{AnyProtocol, TCP, UDP} {
+		parsed, err := ParseProtocol(protocol.String())
+		Assert(t).That(parsed).Equals(protocol)
+		Assert(t).That(err).Equals(nil)
+	}
+	Assert(t).That(Protocol(7).String()).Equals("protocol(7)")
+}
+
+func assertPortMatch(t *testing.T, filter *PortFilter, address string, port uint16, protocol Protocol, expected bool) {
+	t.Helper()
+	if filter.Match(netip.MustParseAddr(address), port, protocol) != expected {
+		t.Errorf("expected match %t for %s %s/%d", expected, address, protocol, port)
+	}
+	Assert(t).That(filter.MatchString(address, port, protocol)).Equals(expected)
+}
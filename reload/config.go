@@ -0,0 +1,122 @@
+package reload
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/smarty/ip-filter"
+)
+
+type configuration struct {
+	constructor func(...string) ipfilter.Filter
+	parser      func(io.Reader) ([]string, error)
+	validator   func([]string) error
+	callback    func(Event)
+	logger      *slog.Logger
+	client      *http.Client
+	maximumSize int64
+	cachePath   string
+}
+
+// NewFiles loads the rules in paths and returns a Reloader that rebuilds its filter when any of the files changes.
+// Changes are found by polling: files whose size and modification time are unchanged are not read, and files that
+// were only touched (same content hash) do not trigger a rebuild. The first load must succeed.
+func NewFiles(paths []string, options ...option) (*Reloader, error) {
+	var config configuration
+	Options.apply(options...)(&config)
+	return newReloader(newFileSource(paths), config)
+}
+
+// NewFeed fetches the rules at url and returns a Reloader that fetches them again on every Reload. Requests are
+// conditional (If-None-Match and If-Modified-Since), and responses with unchanged content do not trigger a rebuild.
+// The first load must succeed, unless Options.Cache names a file holding an earlier copy.
+func NewFeed(url string, options ...option) (*Reloader, error) {
+	var config configuration
+	Options.apply(options...)(&config)
+	return newReloader(newFeedSource(url, config), config)
+}
+
+var Options singleton
+
+type singleton struct{}
+type option func(*configuration)
+
+// Constructor sets how the filter is built from the rules (default: ipfilter.NewCompressed).
+func (singleton) Constructor(value func(...string) ipfilter.Filter) option {
+	return func(this *configuration) { this.constructor = value }
+}
+
+// Parser sets how rules are read from each file (default: ParseLines). A parser error fails the reload.
+func (singleton) Parser(value func(io.Reader) ([]string, error)) option {
+	return func(this *configuration) { this.parser = value }
+}
+
+// Validator sets a check that the complete rule list must pass before it replaces the current filter (default:
+// NotEmpty, so a truncated deploy does not clear the filter). Use nil to accept any list.
+func (singleton) Validator(value func([]string) error) option {
+	return func(this *configuration) { this.validator = value }
+}
+
+// Callback receives the outcome of every load that found changed content (default: none).
+func (singleton) Callback(value func(Event)) option {
+	return func(this *configuration) { this.callback = value }
+}
+
+// Logger logs reload summaries and failures (default: no logging).
+func (singleton) Logger(value *slog.Logger) option {
+	return func(this *configuration) { this.logger = value }
+}
+
+// Client sets the client used by feeds (default: a client with a 30 second timeout).
+func (singleton) Client(value *http.Client) option {
+	return func(this *configuration) { this.client = value }
+}
+
+// MaximumSize sets the largest feed body that is accepted, in bytes (default: 32 MiB, also used for zero).
+func (singleton) MaximumSize(value int64) option {
+	return func(this *configuration) { this.maximumSize = value }
+}
+
+// Cache sets a file where a feed keeps its last good copy, used when the feed cannot be loaded at startup (default:
+// none).
+func (singleton) Cache(path string) option {
+	return func(this *configuration) { this.cachePath = path }
+}
+
+func (singleton) apply(options ...option) option {
+	return func(this *configuration) {
+		for _, item := range Options.defaults(options...) {
+			item(this)
+		}
+
+		if this.maximumSize <= 0 {
+			this.maximumSize = defaultMaximumSize
+		}
+	}
+}
+func (singleton) defaults(options ...option) []option {
+	return append([]option{
+		Options.Constructor(ipfilter.NewCompressed),
+		Options.Parser(ParseLines),
+		Options.Validator(NotEmpty),
+		Options.Callback(func(Event) {}),
+		Options.Client(&http.Client{Timeout: time.Second * 30}),
+		Options.MaximumSize(defaultMaximumSize),
+	}, options...)
+}
+
+// NotEmpty rejects an empty rule list.
+func NotEmpty(rules []string) error {
+	if len(rules) == 0 {
+		return ErrNoRules
+	}
+
+	return nil
+}
+
+var ErrNoRules = errors.New("no rules")
+
+const defaultMaximumSize = 32 << 20
@@ -0,0 +1,117 @@
+package reload
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+type feedSource struct {
+	url          string
+	client       *http.Client
+	maximumSize  int64
+	cachePath    string
+	etag         string
+	lastModified string
+	hash         [sha256.Size]byte
+}
+
+func newFeedSource(url string, config configuration) *feedSource {
+	return &feedSource{url: url, client: config.client, maximumSize: config.maximumSize, cachePath: config.cachePath}
+}
+
+func (this *feedSource) name() string { return this.url }
+
+// read makes a conditional request. Like files, the validators and hash of a response are kept even when its content
+// later fails validation, so a bad feed is reported once rather than on every poll.
+func (this *feedSource) read(ctx context.Context) ([]part, bool, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, this.url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(this.etag) > 0 {
+		request.Header.Set("If-None-Match", this.etag)
+	}
+	if len(this.lastModified) > 0 {
+		request.Header.Set("If-Modified-Since", this.lastModified)
+	}
+
+	response, err := this.client.Do(request)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode == http.StatusNotModified {
+		return nil, false, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("%s: %w: %s", this.url, ErrUnexpectedStatus, response.Status)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(response.Body, this.maximumSize+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(content)) > this.maximumSize {
+		return nil, false, fmt.Errorf("%s: %w (%d bytes)", this.url, ErrFeedTooLarge, this.maximumSize)
+	}
+
+	this.etag, this.lastModified = response.Header.Get("ETag"), response.Header.Get("Last-Modified")
+	sum := sha256.Sum256(content)
+	if sum == this.hash {
+		return nil, false, nil // served again without validators
+	}
+
+	this.hash = sum
+	return []part{{name: this.url, content: content}}, true, nil
+}
+
+func (this *feedSource) cached() ([]part, error) {
+	if len(this.cachePath) == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	content, err := os.ReadFile(this.cachePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return []part{{name: this.cachePath, content: content}}, nil
+}
+
+// store replaces the cache file atomically, so a crash never leaves a partial copy behind.
+func (this *feedSource) store(parts []part) error {
+	if len(this.cachePath) == 0 {
+		return nil
+	}
+
+	temporary, err := os.CreateTemp(filepath.Dir(this.cachePath), filepath.Base(this.cachePath)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(temporary.Name()) }()
+
+	for _, part := range parts {
+		if _, err = temporary.Write(part.content); err != nil {
+			_ = temporary.Close()
+			return err
+		}
+	}
+
+	if err = temporary.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temporary.Name(), this.cachePath)
+}
+
+var (
+	ErrUnexpectedStatus = errors.New("unexpected feed status")
+	ErrFeedTooLarge     = errors.New("feed exceeds the maximum size")
+)
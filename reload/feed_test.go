@@ -0,0 +1,149 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestFeedUsesConditionalRequests(t *testing.T) {
+	feed := newFakeFeed("10.0.0.0/8\n")
+	server := httptest.NewServer(feed)
+	defer server.Close()
+
+	var events []Event
+	reloader, err := NewFeed(server.URL, Options.Callback(func(event Event) { events = append(events, event) }))
+	Assert(t).That(err).Equals(nil)
+	Assert(t).That(reloader.Contains("10.1.2.3")).Equals(true)
+
+	Assert(t).That(reloader.Reload(context.Background())).Equals(nil)
+	feed.update("10.0.0.0/8\n11.0.0.0/8\n")
+	Assert(t).That(reloader.Reload(context.Background())).Equals(nil)
+	Assert(t).That(reloader.Contains("11.1.2.3")).Equals(true)
+
+	Assert(t).That(feed.statuses()).Equals([]int{http.StatusOK, http.StatusNotModified, http.StatusOK})
+	Assert(t).That(feed.since).Equals([]string{"", "Mon, 02 Jan 2006 15:04:05 GMT", "Mon, 02 Jan 2006 15:04:05 GMT"})
+	Assert(t).That(events).Equals([]Event{
+		{Source: server.URL, Generation: 1, Added: 1, Total: 1, Rules: []string{"10.0.0.0/8"}},
+		{Source: server.URL, Generation: 2, Added: 1, Total: 2, Rules: []string{"10.0.0.0/8", "11.0.0.0/8"}},
+	})
+}
+func TestFeedWithoutValidatorsComparesContent(t *testing.T) {
+	feed := newFakeFeed("10.0.0.0/8\n")
+	feed.validators = false
+	server := httptest.NewServer(feed)
+	defer server.Close()
+
+	reloader, _ := NewFeed(server.URL)
+	Assert(t).That(reloader.Reload(context.Background())).Equals(nil)
+
+	generation, _ := reloader.Generation()
+	Assert(t).That(generation).Equals(uint64(1))
+	Assert(t).That(feed.statuses()).Equals([]int{http.StatusOK, http.StatusOK})
+}
+func TestFeedKeepsPreviousFilterOnFailure(t *testing.T) {
+	feed := newFakeFeed("10.0.0.0/8\n")
+	server := httptest.NewServer(feed)
+	defer server.Close()
+
+	reloader, _ := NewFeed(server.URL, Options.MaximumSize(20))
+
+	feed.fail(http.StatusInternalServerError)
+	err := reloader.Reload(context.Background())
+	Assert(t).That(errors.Is(err, ErrUnexpectedStatus)).Equals(true)
+	Assert(t).That(err.Error()).Equals(server.URL + ": unexpected feed status: 500 Internal Server Error")
+
+	feed.update("10.0.0.0/8\n11.0.0.0/8\n12.0.0.0/8\n")
+	Assert(t).That(errors.Is(reloader.Reload(context.Background()), ErrFeedTooLarge)).Equals(true)
+
+	feed.update("11.0.0.0/88\n")
+	Assert(t).That(reloader.Reload(context.Background()) != nil).Equals(true)
+
+	Assert(t).That(reloader.Contains("10.1.2.3")).Equals(true)
+	Assert(t).That(reloader.Contains("11.1.2.3")).Equals(false)
+}
+func TestFeedFallsBackToCacheOnColdStart(t *testing.T) {
+	cache := filepath.Join(t.TempDir(), "feed.cache")
+	feed := newFakeFeed(`{"prefixes": [{"ip_prefix": "10.0.0.0/8"}]}`)
+	server := httptest.NewServer(feed)
+
+	_, err := NewFeed(server.URL, Options.Cache(cache), Options.Parser(ParseJSON("ip_prefix")))
+	Assert(t).That(err).Equals(nil)
+	content, _ := os.ReadFile(cache)
+	Assert(t).That(string(content)).Equals(`{"prefixes": [{"ip_prefix": "10.0.0.0/8"}]}`)
+
+	server.Close()
+	var events []Event
+	reloader, err := NewFeed(server.URL, Options.Cache(cache), Options.Parser(ParseJSON("ip_prefix")),
+		Options.Callback(func(event Event) { events = append(events, event) }))
+	Assert(t).That(err).Equals(nil)
+	Assert(t).That(reloader.Contains("10.1.2.3")).Equals(true)
+	Assert(t).That(len(events)).Equals(2)
+	Assert(t).That(events[0].Err != nil).Equals(true)
+	Assert(t).That(events[1]).Equals(Event{Source: server.URL + " (cache)", Generation: 1, Added: 1, Total: 1,
+		Rules: []string{"10.0.0.0/8"}})
+
+	_, err = NewFeed(server.URL, Options.Cache(filepath.Join(t.TempDir(), "missing.cache")))
+	Assert(t).That(err != nil).Equals(true)
+	_, err = NewFeed(server.URL)
+	Assert(t).That(err != nil).Equals(true)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type fakeFeed struct {
+	lock       sync.Mutex
+	content    string
+	version    int
+	status     int
+	validators bool
+	served     []int
+	since      []string
+}
+
+func newFakeFeed(content string) *fakeFeed {
+	return &fakeFeed{content: content, version: 1, status: http.StatusOK, validators: true}
+}
+
+func (this *fakeFeed) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	status := this.status
+	etag := `"` + string(rune('0'+this.version)) + `"`
+	if status == http.StatusOK && this.validators {
+		response.Header().Set("ETag", etag)
+		response.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if request.Header.Get("If-None-Match") == etag {
+			status = http.StatusNotModified
+		}
+	}
+
+	this.served = append(this.served, status)
+	this.since = append(this.since, request.Header.Get("If-Modified-Since"))
+	response.WriteHeader(status)
+	if status == http.StatusOK {
+		_, _ = response.Write([]byte(this.content))
+	}
+}
+func (this *fakeFeed) update(content string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.content, this.status = content, http.StatusOK
+	this.version++
+}
+func (this *fakeFeed) fail(status int) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.status = status
+}
+func (this *fakeFeed) statuses() []int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return append([]int{}, this.served...)
+}
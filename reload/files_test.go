@@ -0,0 +1,178 @@
+package reload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smarty/ip-filter"
+	"github.com/smarty/ip-filter/internal/testfiles"
+)
+
+func TestFilesLoadAndReload(t *testing.T) {
+	directory := t.TempDir()
+	first := testfiles.Write(t, directory, "first.txt", "10.0.0.0/8\n# comment\n\n2600:f0f0:2::/48 # inline\n", 1)
+	second := testfiles.Write(t, directory, "second.txt", "11.0.0.0/8\n", 1)
+
+	var events []Event
+	reloader, err := NewFiles([]string{first, second}, Options.Callback(func(event Event) { events = append(events, event) }))
+	Assert(t).That(err).Equals(nil)
+	Assert(t).That(reloader.Contains("10.1.2.3")).Equals(true)
+	Assert(t).That(reloader.Contains("11.1.2.3")).Equals(true)
+	Assert(t).That(reloader.Contains("2600:f0f0:2::1")).Equals(true)
+	Assert(t).That(reloader.Contains("12.1.2.3")).Equals(false)
+
+	testfiles.Write(t, directory, "second.txt", "12.0.0.0/8\n13.0.0.0/8\n", 2)
+	Assert(t).That(reloader.Reload(context.Background())).Equals(nil)
+	Assert(t).That(reloader.Contains("11.1.2.3")).Equals(false)
+	Assert(t).That(reloader.Contains("13.1.2.3")).Equals(true)
+
+	generation, loaded := reloader.Generation()
+	Assert(t).That(generation).Equals(uint64(2))
+	Assert(t).That(time.Since(loaded) < time.Minute).Equals(true)
+	Assert(t).That(reloader.Stats().Rules()).Equals(4)
+	Assert(t).That(events).Equals([]Event{
+		{Source: first + "," + second, Generation: 1, Added: 3, Total: 3,
+			Rules: []string{"10.0.0.0/8", "2600:f0f0:2::/48", "11.0.0.0/8"}},
+		{Source: first + "," + second, Generation: 2, Added: 2, Removed: 1, Total: 4,
+			Rules: []string{"10.0.0.0/8", "2600:f0f0:2::/48", "12.0.0.0/8", "13.0.0.0/8"}},
+	})
+}
+func TestFilesSkipUnchangedAndTouchedFiles(t *testing.T) {
+	directory := t.TempDir()
+	path := testfiles.Write(t, directory, "rules.txt", "10.0.0.0/8\n", 1)
+
+	var events []Event
+	reloader, _ := NewFiles([]string{path}, Options.Callback(func(event Event) { events = append(events, event) }))
+
+	Assert(t).That(reloader.Reload(context.Background())).Equals(nil)
+	testfiles.Write(t, directory, "rules.txt", "10.0.0.0/8\n", 2) // same content, new modification time
+	Assert(t).That(reloader.Reload(context.Background())).Equals(nil)
+
+	generation, _ := reloader.Generation()
+	Assert(t).That(generation).Equals(uint64(1))
+	Assert(t).That(len(events)).Equals(1)
+}
+func TestFilesKeepPreviousFilterWhenValidationFails(t *testing.T) {
+	directory := t.TempDir()
+	path := testfiles.Write(t, directory, "rules.txt", "10.0.0.0/8\n", 1)
+
+	var events []Event
+	reloader, _ := NewFiles([]string{path}, Options.Callback(func(event Event) { events = append(events, event) }))
+	filter := reloader.Filter()
+
+	testfiles.Write(t, directory, "rules.txt", "11.0.0.0/8\n11.0.0.0/33\n", 2)
+	err := reloader.Reload(context.Background())
+	Assert(t).That(errors.Is(err, ipfilter.ErrInvalidSubnetBits)).Equals(true)
+	Assert(t).That(err.Error()).Equals(path + `: line 2: invalid subnet bits: "11.0.0.0/33"`)
+	Assert(t).That(reloader.Filter()).Equals(filter)
+	Assert(t).That(reloader.Contains("10.1.2.3")).Equals(true)
+	Assert(t).That(reloader.Contains("11.1.2.3")).Equals(false)
+	Assert(t).That(events[1]).Equals(Event{Source: path, Generation: 1, Total: 1, Err: err})
+
+	Assert(t).That(reloader.Reload(context.Background())).Equals(nil) // reported once
+	Assert(t).That(len(events)).Equals(2)
+
+	testfiles.Write(t, directory, "rules.txt", "", 3)
+	Assert(t).That(reloader.Reload(context.Background())).Equals(ErrNoRules)
+	Assert(t).That(reloader.Contains("10.1.2.3")).Equals(true)
+
+	_ = os.Remove(path)
+	Assert(t).That(errors.Is(reloader.Reload(context.Background()), os.ErrNotExist)).Equals(true)
+	Assert(t).That(reloader.Contains("10.1.2.3")).Equals(true)
+}
+func TestFilesFirstLoadMustSucceed(t *testing.T) {
+	reloader, err := NewFiles([]string{filepath.Join(t.TempDir(), "missing.txt")})
+	Assert(t).That(reloader == nil).Equals(true)
+	Assert(t).That(errors.Is(err, os.ErrNotExist)).Equals(true)
+
+	path := testfiles.Write(t, t.TempDir(), "empty.txt", "", 1)
+	_, err = NewFiles([]string{path})
+	Assert(t).That(err).Equals(ErrNoRules)
+
+	reloader, err = NewFiles([]string{path}, Options.Validator(nil))
+	Assert(t).That(err).Equals(nil)
+	Assert(t).That(reloader.Contains("10.1.2.3")).Equals(false)
+}
+func TestFilesWithCustomConstructorAndParser(t *testing.T) {
+	path := testfiles.Write(t, t.TempDir(), "rules.csv", "10.0.0.0/8,11.0.0.0/8", 1)
+	reloader, err := NewFiles([]string{path},
+		Options.Constructor(func(rules ...string) ipfilter.Filter { return ipfilter.NewFlat(rules...) }),
+		Options.Parser(func(reader io.Reader) ([]string, error) {
+			content, err := io.ReadAll(reader)
+			return strings.Split(string(content), ","), err
+		}))
+
+	Assert(t).That(err).Equals(nil)
+	Assert(t).That(reloader.Contains("11.1.2.3")).Equals(true)
+	Assert(t).That(reflect.TypeOf(reloader.Filter()).String()).Equals("*ipfilter.FlatFilter")
+}
+func TestFilesLogSummaries(t *testing.T) {
+	directory := t.TempDir()
+	path := testfiles.Write(t, directory, "rules.txt", "10.0.0.0/8\n", 1)
+	buffer := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buffer, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, attribute slog.Attr) slog.Attr {
+			if attribute.Key == slog.TimeKey || attribute.Key == "source" {
+				return slog.Attr{}
+			}
+			return attribute
+		},
+	}))
+
+	reloader, _ := NewFiles([]string{path}, Options.Logger(logger))
+	testfiles.Write(t, directory, "rules.txt", "10.0.0.0/8\n11.0.0.0/8\n12.0.0.0/8\n", 2)
+	_ = reloader.Reload(context.Background())
+	testfiles.Write(t, directory, "rules.txt", "", 3)
+	_ = reloader.Reload(context.Background())
+
+	Assert(t).That(buffer.String()).Equals(
+		"level=INFO msg=\"ipfilter: rules reloaded\" generation=1 added=1 removed=0 total=1\n" +
+			"level=INFO msg=\"ipfilter: rules reloaded\" generation=2 added=2 removed=0 total=3\n" +
+			"level=WARN msg=\"ipfilter: reload failed, keeping previous rules\" generation=2 error=\"no rules\"\n")
+}
+func TestRunPollsUntilCancelled(t *testing.T) {
+	directory := t.TempDir()
+	path := testfiles.Write(t, directory, "rules.txt", "10.0.0.0/8\n", 1)
+
+	events := make(chan Event, 10)
+	reloader, _ := NewFiles([]string{path}, Options.Callback(func(event Event) { events <- event }))
+	<-events
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { reloader.Run(ctx, time.Millisecond); close(done) }()
+
+	testfiles.Write(t, directory, "rules.txt", "11.0.0.0/8\n", 2)
+	Assert(t).That((<-events).Generation).Equals(uint64(2))
+	Assert(t).That(reloader.Contains("11.1.2.3")).Equals(true)
+
+	cancel()
+	<-done
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type That struct{ t *testing.T }
+type Assertion struct {
+	*testing.T
+	actual interface{}
+}
+
+func Assert(t *testing.T) *That                       { return &That{t: t} }
+func (this *That) That(actual interface{}) *Assertion { return &Assertion{T: this.t, actual: actual} }
+
+func (this *Assertion) Equals(expected interface{}) {
+	this.Helper()
+	if !reflect.DeepEqual(this.actual, expected) {
+		this.Errorf("\nExpected: %#v\nActual:   %#v", expected, this.actual)
+	}
+}
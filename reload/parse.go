@@ -0,0 +1,83 @@
+package reload
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/smarty/ip-filter"
+)
+
+// ParseLines reads one rule per line. Blank lines and everything after a '#' are ignored. A rule the filters would
+// skip fails the whole file, reported with its line number.
+func ParseLines(reader io.Reader) (rules []string, err error) {
+	scanner := bufio.NewScanner(reader)
+
+	for number := 1; scanner.Scan(); number++ {
+		rule, _, _ := strings.Cut(scanner.Text(), "#")
+		if rule = strings.TrimSpace(rule); len(rule) == 0 {
+			continue
+		}
+
+		if _, err = ipfilter.ParseRule(rule); err != nil {
+			return nil, fmt.Errorf("line %d: %w: %q", number, err, rule)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, scanner.Err()
+}
+
+// ParseJSON returns a parser that collects the values of the named fields anywhere in a JSON document, such as
+// "ip_prefix" and "ipv6_prefix" in published cloud provider ranges. A field may hold a rule or an array of rules.
+func ParseJSON(fields ...string) func(io.Reader) ([]string, error) {
+	return func(reader io.Reader) (rules []string, err error) {
+		var document any
+		if err = json.NewDecoder(reader).Decode(&document); err != nil {
+			return nil, err
+		}
+
+		if err = collectJSON(document, false, fields, &rules); err != nil {
+			return nil, err
+		}
+
+		return rules, nil
+	}
+}
+func collectJSON(value any, selected bool, fields []string, rules *[]string) error {
+	switch value := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if err := collectJSON(value[key], slices.Contains(fields, key), fields, rules); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range value {
+			if err := collectJSON(item, selected, fields, rules); err != nil {
+				return err
+			}
+		}
+	case string:
+		if !selected {
+			return nil
+		}
+		if _, err := ipfilter.ParseRule(value); err != nil {
+			return fmt.Errorf("%w: %q", err, value)
+		}
+		*rules = append(*rules, value)
+	}
+
+	return nil
+}
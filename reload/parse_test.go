@@ -0,0 +1,42 @@
+package reload
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/smarty/ip-filter"
+)
+
+func TestParseLines(t *testing.T) {
+	rules, err := ParseLines(strings.NewReader("# header\n10.0.0.0/8\n\n  11.0.0.0/8  # inline\r\n2600:f0f0:2::/48"))
+	Assert(t).That(err).Equals(nil)
+	Assert(t).That(rules).Equals([]string{"10.0.0.0/8", "11.0.0.0/8", "2600:f0f0:2::/48"})
+
+	rules, err = ParseLines(strings.NewReader("10.0.0.0/8\n\n10.0.0.1\n"))
+	Assert(t).That(rules == nil).Equals(true)
+	Assert(t).That(errors.Is(err, ipfilter.ErrMissingSubnetBits)).Equals(true)
+	Assert(t).That(err.Error()).Equals(`line 3: missing subnet bits (expected address/bits): "10.0.0.1"`)
+
+	rules, err = ParseLines(strings.NewReader(""))
+	Assert(t).That(rules == nil).Equals(true)
+	Assert(t).That(err).Equals(nil)
+}
+func TestParseJSON(t *testing.T) {
+	parse := ParseJSON("ip_prefix", "ipv6_prefix", "extra")
+	rules, err := parse(strings.NewReader(`{
+		"syncToken": "1700000000",
+		"prefixes": [{"ip_prefix": "3.0.0.0/9", "region": "us-east-1"}, {"ip_prefix": "10.0.0.0/8"}],
+		"ipv6_prefixes": [{"ipv6_prefix": "2600:f0f0:2::/48", "region": "us-west-2"}],
+		"extra": ["11.0.0.0/8", "12.0.0.0/8"]
+	}`))
+	Assert(t).That(err).Equals(nil)
+	Assert(t).That(rules).Equals([]string{"11.0.0.0/8", "12.0.0.0/8", "2600:f0f0:2::/48", "3.0.0.0/9", "10.0.0.0/8"})
+
+	rules, err = parse(strings.NewReader(`{"prefixes": [{"ip_prefix": "3.0.0.0/40"}]}`))
+	Assert(t).That(rules == nil).Equals(true)
+	Assert(t).That(err.Error()).Equals(`invalid subnet bits: "3.0.0.0/40"`)
+
+	_, err = parse(strings.NewReader(`{"prefixes": [`))
+	Assert(t).That(err != nil).Equals(true)
+}
@@ -0,0 +1,233 @@
+package reload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/smarty/ip-filter"
+)
+
+// Reloader is a filter whose rules are reloaded from a source. Lookups read the current generation without locking;
+// a reload builds the next generation completely before swapping it in, and a failed reload keeps the current one.
+type Reloader struct {
+	source  source
+	config  configuration
+	lock    sync.Mutex // serializes reloads
+	current atomic.Pointer[generation]
+}
+
+// source reads the raw content of a rule set; changed is false when the content is known to be the same as in the
+// previous read.
+type source interface {
+	name() string
+	read(ctx context.Context) (parts []part, changed bool, err error)
+}
+
+// cachingSource is a source that keeps the last content that loaded successfully for cold starts.
+type cachingSource interface {
+	source
+	cached() ([]part, error)
+	store(parts []part) error
+}
+type part struct {
+	name    string
+	content []byte
+}
+
+type generation struct {
+	filter   ipfilter.Filter
+	prefixes map[netip.Prefix]struct{}
+	number   uint64
+	loaded   time.Time
+}
+
+func newReloader(source source, config configuration) (*Reloader, error) {
+	this := &Reloader{source: source, config: config}
+	this.current.Store(&generation{filter: config.constructor()})
+
+	if err := this.Reload(context.Background()); err != nil && !this.loadCache() {
+		return nil, err
+	}
+
+	return this, nil
+}
+
+// loadCache falls back to the last content that loaded successfully, for sources that keep it.
+func (this *Reloader) loadCache() bool {
+	source, ok := this.source.(cachingSource)
+	if !ok {
+		return false
+	}
+
+	parts, err := source.cached()
+	if err != nil {
+		return false
+	}
+
+	rules, err := this.parse(parts)
+	if err != nil {
+		return false
+	}
+
+	event := this.swap(rules)
+	event.Source += " (cache)"
+	this.report(event)
+	return true
+}
+
+// Reload checks the source once and swaps in a new filter if the content changed and passes validation. The error
+// is also reported through the callback; the current filter stays in place when it is not nil.
+func (this *Reloader) Reload(ctx context.Context) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	parts, changed, err := this.source.read(ctx)
+	if err == nil && !changed {
+		return nil
+	}
+
+	var rules []string
+	if err == nil {
+		rules, err = this.parse(parts)
+	}
+
+	event := Event{Source: this.source.name(), Err: err}
+	if err == nil {
+		event = this.swap(rules)
+		this.store(parts)
+	} else {
+		previous := this.current.Load()
+		event.Generation, event.Total = previous.number, len(previous.prefixes)
+	}
+
+	this.report(event)
+	return err
+}
+func (this *Reloader) parse(parts []part) (rules []string, err error) {
+	for _, part := range parts {
+		parsed, err := this.config.parser(bytes.NewReader(part.content))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", part.name, err)
+		}
+		rules = append(rules, parsed...)
+	}
+
+	if this.config.validator != nil {
+		err = this.config.validator(rules)
+	}
+
+	return rules, err
+}
+func (this *Reloader) swap(rules []string) Event {
+	previous := this.current.Load()
+	next := &generation{
+		filter:   this.config.constructor(rules...),
+		prefixes: make(map[netip.Prefix]struct{}, len(rules)),
+		number:   previous.number + 1,
+		loaded:   time.Now(),
+	}
+
+	event := Event{Source: this.source.name(), Generation: next.number, Rules: rules}
+	for _, rule := range rules {
+		if prefix, err := ipfilter.ParseRule(rule); err == nil {
+			next.prefixes[prefix] = struct{}{}
+		}
+	}
+	for prefix := range next.prefixes {
+		if _, found := previous.prefixes[prefix]; !found {
+			event.Added++
+		}
+	}
+	event.Removed = len(previous.prefixes) - (len(next.prefixes) - event.Added)
+	event.Total = len(next.prefixes)
+
+	this.current.Store(next)
+	return event
+}
+func (this *Reloader) store(parts []part) {
+	source, ok := this.source.(cachingSource)
+	if !ok {
+		return
+	}
+
+	if err := source.store(parts); err != nil && this.config.logger != nil {
+		this.config.logger.Warn("ipfilter: rules not cached",
+			slog.String("source", this.source.name()), slog.String("error", err.Error()))
+	}
+}
+func (this *Reloader) report(event Event) {
+	this.config.callback(event)
+
+	if this.config.logger == nil {
+		return
+	}
+
+	if event.Err != nil {
+		this.config.logger.Warn("ipfilter: reload failed, keeping previous rules",
+			slog.String("source", event.Source), slog.Uint64("generation", event.Generation),
+			slog.String("error", event.Err.Error()))
+		return
+	}
+
+	this.config.logger.Info("ipfilter: rules reloaded",
+		slog.String("source", event.Source), slog.Uint64("generation", event.Generation),
+		slog.Int("added", event.Added), slog.Int("removed", event.Removed), slog.Int("total", event.Total))
+}
+
+// Run calls Reload every interval until ctx is cancelled.
+func (this *Reloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = this.Reload(ctx)
+		}
+	}
+}
+
+func (this *Reloader) Contains(ipAddress string) bool {
+	return this.current.Load().filter.Contains(ipAddress)
+}
+func (this *Reloader) ContainsAddr(address netip.Addr) bool {
+	return ipfilter.ContainsAddr(this.current.Load().filter, address)
+}
+
+// Filter returns the current filter.
+func (this *Reloader) Filter() ipfilter.Filter { return this.current.Load().filter }
+
+// Generation returns the number of successful loads and when the last one happened.
+func (this *Reloader) Generation() (uint64, time.Time) {
+	current := this.current.Load()
+	return current.number, current.loaded
+}
+
+// Stats forwards to the current filter, if it reports stats.
+func (this *Reloader) Stats() ipfilter.Stats {
+	if filter, ok := this.current.Load().filter.(interface{ Stats() ipfilter.Stats }); ok {
+		return filter.Stats()
+	}
+
+	return ipfilter.Stats{}
+}
+
+// Event describes a load that found changed content. Added, Removed and Total count distinct prefixes. When Err is
+// set the previous filter was kept and Generation and Total describe it.
+type Event struct {
+	Source     string
+	Generation uint64
+	Added      int
+	Removed    int
+	Total      int
+	Rules      []string // the rules now in effect; nil when Err is set
+	Err        error
+}
@@ -0,0 +1,45 @@
+package ipfilter
+
+import (
+	"errors"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ParseRule parses a rule the way the constructors do and explains why a rule would be skipped. The prefix is masked
+// to its subnet bits; IPv6 prefixes keep only the first 64 bits, like every filter in this package.
+func ParseRule(rule string) (netip.Prefix, error) {
+	family, numericIP, subnetBits, ok := parseSubnetMask(rule)
+	if !ok {
+		return netip.Prefix{}, ruleError(rule)
+	}
+
+	return formatPrefix(family, numericIP, subnetBits), nil
+}
+
+// ruleError explains why parseSubnetMask rejects a rule.
+func ruleError(rule string) error {
+	if len(rule) == 0 {
+		return ErrEmptyRule
+	}
+
+	_, bits, found := strings.Cut(rule, subnetMaskSeparator)
+	if !found {
+		return ErrMissingSubnetBits
+	}
+
+	subnetBits, _ := strconv.Atoi(bits)
+	if subnetBits <= 0 || subnetBits > ipv4BitCount && !strings.Contains(rule, ":") {
+		return ErrInvalidSubnetBits
+	}
+
+	return ErrInvalidAddress
+}
+
+var (
+	ErrEmptyRule         = errors.New("empty rule")
+	ErrMissingSubnetBits = errors.New("missing subnet bits (expected address/bits)")
+	ErrInvalidSubnetBits = errors.New("invalid subnet bits")
+	ErrInvalidAddress    = errors.New("invalid or unspecified base address")
+)
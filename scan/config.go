@@ -0,0 +1,35 @@
+package scan
+
+type configuration struct {
+	extract Extractor
+	output  Output
+}
+
+var Options singleton
+
+type singleton struct{}
+type option func(*configuration)
+
+// Format sets how the client address is found in each line (default: Combined).
+func (singleton) Format(value Extractor) option {
+	return func(this *configuration) { this.extract = value }
+}
+
+// Output sets which lines are written (default: Matching).
+func (singleton) Output(value Output) option {
+	return func(this *configuration) { this.output = value }
+}
+
+func (singleton) apply(options ...option) option {
+	return func(this *configuration) {
+		for _, item := range Options.defaults(options...) {
+			item(this)
+		}
+	}
+}
+func (singleton) defaults(options ...option) []option {
+	return append([]option{
+		Options.Format(Combined()),
+		Options.Output(Matching),
+	}, options...)
+}
@@ -0,0 +1,114 @@
+package scan
+
+import (
+	"bytes"
+	"net/netip"
+	"regexp"
+)
+
+// Extractor finds the client address in a log line. IPv4-mapped IPv6 addresses are returned unmapped.
+type Extractor func(line []byte) (netip.Addr, bool)
+
+// Combined reads the first field of the common and combined log formats of nginx and Apache ($remote_addr).
+func Combined() Extractor {
+	return func(line []byte) (netip.Addr, bool) {
+		return parseAddress(field(line, ' ', 0))
+	}
+}
+
+// JSON reads the string value of the named key in JSON lines, such as "remote_addr". The first occurrence of the
+// key is used wherever it is nested; the line is not otherwise decoded, so that scanning keeps up with large files.
+// The value may carry a port ("10.1.2.3:443" or "[2600::1]:443").
+func JSON(key string) Extractor {
+	quoted := []byte(`"` + key + `"`)
+
+	return func(line []byte) (netip.Addr, bool) {
+		for rest := line; ; {
+			index := bytes.Index(rest, quoted)
+			if index < 0 {
+				return netip.Addr{}, false
+			}
+
+			rest = bytes.TrimLeft(rest[index+len(quoted):], " \t")
+			if len(rest) == 0 || rest[0] != ':' {
+				continue // the key appeared as a value
+			}
+
+			value := bytes.TrimLeft(rest[1:], " \t")
+			if len(value) == 0 || value[0] != '"' {
+				return netip.Addr{}, false
+			}
+
+			end := bytes.IndexByte(value[1:], '"')
+			if end < 0 {
+				return netip.Addr{}, false
+			}
+
+			return parseAddress(value[1 : 1+end])
+		}
+	}
+}
+
+// ALB reads the client:port field of AWS Application Load Balancer access logs.
+func ALB() Extractor {
+	return func(line []byte) (netip.Addr, bool) {
+		client := field(line, ' ', 3)
+		if index := bytes.LastIndexByte(client, ':'); index >= 0 {
+			client = bytes.Trim(client[:index], "[]") // always followed by the port, even for IPv6
+		}
+		return parseAddress(client)
+	}
+}
+
+// CloudFront reads the c-ip field of Amazon CloudFront standard (tab-separated) logs; header lines hold no address.
+func CloudFront() Extractor {
+	return func(line []byte) (netip.Addr, bool) {
+		if len(line) > 0 && line[0] == '#' {
+			return netip.Addr{}, false
+		}
+		return parseAddress(field(line, '\t', 4))
+	}
+}
+
+// Regexp reads the address captured by the group named "ip", or else by the first group, or else the whole match.
+func Regexp(pattern *regexp.Regexp) Extractor {
+	group := pattern.SubexpIndex("ip")
+	if group < 0 {
+		group = min(pattern.NumSubexp(), 1)
+	}
+
+	return func(line []byte) (netip.Addr, bool) {
+		match := pattern.FindSubmatchIndex(line)
+		if match == nil || match[2*group] < 0 {
+			return netip.Addr{}, false
+		}
+		return parseAddress(line[match[2*group]:match[2*group+1]])
+	}
+}
+
+// field returns the field at index, counting from zero; it is empty when the line has fewer fields.
+func field(line []byte, separator byte, index int) []byte {
+	for ; index > 0; index-- {
+		next := bytes.IndexByte(line, separator)
+		if next < 0 {
+			return nil
+		}
+		line = line[next+1:]
+	}
+
+	if end := bytes.IndexByte(line, separator); end >= 0 {
+		return line[:end]
+	}
+	return line
+}
+func parseAddress(value []byte) (netip.Addr, bool) {
+	if address, err := netip.ParseAddr(string(value)); err == nil {
+		return address.Unmap(), true
+	}
+
+	if addressPort, err := netip.ParseAddrPort(string(value)); err == nil {
+		return addressPort.Addr().Unmap(), true
+	}
+
+	return netip.Addr{}, false
+}
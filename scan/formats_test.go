@@ -0,0 +1,63 @@
+package scan
+
+import (
+	"net/netip"
+	"regexp"
+	"testing"
+)
+
+func TestCombined(t *testing.T) {
+	assertExtracts(t, Combined(),
+		`203.0.113.9 - - [19/Oct/2026:13:55:36 -0700] "GET / HTTP/1.1" 200 2326 "-" "curl/8.0"`, "203.0.113.9")
+	assertExtracts(t, Combined(), `2600:f0f0::1 - frank [19/Oct/2026:13:55:36 -0700] "GET / HTTP/1.1" 200 2326`,
+		"2600:f0f0::1")
+	assertExtracts(t, Combined(), `::ffff:10.1.2.3 - - [19/Oct/2026:13:55:36 -0700] "GET / HTTP/1.1" 200 0`, "10.1.2.3")
+	assertExtracts(t, Combined(), `localhost - - [19/Oct/2026:13:55:36 -0700] "GET / HTTP/1.1" 200 0`, "")
+	assertExtracts(t, Combined(), ``, "")
+}
+func TestJSON(t *testing.T) {
+	extract := JSON("remote_addr")
+	assertExtracts(t, extract, `{"time": "2026-10-19T13:55:36Z", "remote_addr": "2600:f0f0::1", "status": 200}`,
+		"2600:f0f0::1")
+	assertExtracts(t, extract, `{"http":{"remote_addr":"10.1.2.3:52114"}}`, "10.1.2.3")
+	assertExtracts(t, extract, `{"remote_addr" : "[2600:f0f0::1]:443"}`, "2600:f0f0::1")
+	assertExtracts(t, extract, `{"note": "remote_addr", "remote_addr": "10.1.2.3"}`, "10.1.2.3")
+	assertExtracts(t, extract, `{"remote_addr": null}`, "")
+	assertExtracts(t, extract, `{"remote_addr": "10.1.2.3`, "")
+	assertExtracts(t, extract, `{"client": "10.1.2.3"}`, "")
+}
+func TestALB(t *testing.T) {
+	assertExtracts(t, ALB(), `https 2026-10-19T23:39:43.945958Z app/my-loadbalancer/50dc6c495c0c9188 `+
+		`192.168.131.39:2817 10.0.0.1:80 0.086 0.048 0.037 200 200 0 57 "GET https://www.example.com:443/ HTTP/1.1"`,
+		"192.168.131.39")
+	assertExtracts(t, ALB(), `h2 2026-10-19T23:39:43.945958Z app/my-loadbalancer/50dc6c495c0c9188 `+
+		`2600:f0f0::1:443 10.0.0.1:80 0.086 0.048 0.037 200 200 0 57`, "2600:f0f0::1")
+	assertExtracts(t, ALB(), `http 2026-10-19T23:39:43.945958Z app/my-loadbalancer/50dc6c495c0c9188 - - -1`, "")
+}
+func TestCloudFront(t *testing.T) {
+	assertExtracts(t, CloudFront(), "2026-10-19\t21:02:18\tLHR62-C2\t2390282\t192.0.2.202\tGET\t"+
+		"d111111abcdef8.cloudfront.net\t/index.html\t200", "192.0.2.202")
+	assertExtracts(t, CloudFront(), "#Fields: date time x-edge-location sc-bytes c-ip cs-method", "")
+	assertExtracts(t, CloudFront(), "#Version: 1.0", "")
+}
+func TestRegexp(t *testing.T) {
+	assertExtracts(t, Regexp(regexp.MustCompile(`client=(?P<ip>\S+)`)), "level=info client=10.1.2.3 path=/", "10.1.2.3")
+	assertExtracts(t, Regexp(regexp.MustCompile(`(\w+)=(\S+)$`)), "x-forwarded-for=10.1.2.3", "")
+	assertExtracts(t, Regexp(regexp.MustCompile(`from (\S+) port`)), "Failed password from 10.1.2.3 port 22", "10.1.2.3")
+	assertExtracts(t, Regexp(regexp.MustCompile(`\d+\.\d+\.\d+\.\d+`)), "blocked 10.1.2.3 twice", "10.1.2.3")
+	assertExtracts(t, Regexp(regexp.MustCompile(`client=(?P<ip>\S+)?;`)), "client=;", "")
+	assertExtracts(t, Regexp(regexp.MustCompile(`client=(\S+)`)), "no client here", "")
+}
+
+func assertExtracts(t *testing.T, extract Extractor, line, expected string) {
+	t.Helper()
+
+	address, ok := extract([]byte(line))
+	if len(expected) == 0 {
+		Assert(t).That(ok).Equals(false)
+		return
+	}
+
+	Assert(t).That(ok).Equals(true)
+	Assert(t).That(address).Equals(netip.MustParseAddr(expected))
+}
@@ -0,0 +1,129 @@
+package scan
+
+import (
+	"bufio"
+	"io"
+	"net/netip"
+	"strings"
+
+	"github.com/smarty/ip-filter"
+)
+
+// Matcher reports the sources with a rule containing an address, with the most specific such rule of each;
+// *ipfilter.Composite is a Matcher, and config.Engine provides one through its Composite method.
+type Matcher interface {
+	Lookup(netip.Addr) []ipfilter.SourceMatch
+}
+
+// Scanner streams log lines through a Matcher and writes the lines selected by its Output.
+type Scanner struct {
+	matcher Matcher
+	config  configuration
+}
+
+func New(matcher Matcher, options ...option) *Scanner {
+	var config configuration
+	Options.apply(options...)(&config)
+	return &Scanner{matcher: matcher, config: config}
+}
+
+// Summary counts the lines of a scan. Unparsed lines held no address the format could find.
+type Summary struct {
+	Lines    int
+	Matched  int
+	Unparsed int
+}
+
+// Match returns the sources matching the client address of the line, and whether the line held an address.
+func (this *Scanner) Match(line []byte) ([]ipfilter.SourceMatch, bool) {
+	address, ok := this.config.extract(line)
+	if !ok {
+		return nil, false
+	}
+
+	return this.matcher.Lookup(address), true
+}
+
+// Scan reads lines until the end of reader, or until writing fails, and writes the selected lines to writer. Lines
+// longer than 1 MiB fail the scan.
+func (this *Scanner) Scan(reader io.Reader, writer io.Writer) (summary Summary, err error) {
+	lines := bufio.NewScanner(reader)
+	lines.Buffer(make([]byte, 64*1024), maximumLineLength)
+	output := bufio.NewWriterSize(writer, 64*1024)
+
+	for lines.Scan() {
+		line := lines.Bytes()
+		summary.Lines++
+
+		matches, parsed := this.Match(line)
+		if !parsed {
+			summary.Unparsed++
+		} else if len(matches) > 0 {
+			summary.Matched++
+		}
+
+		if err = this.write(output, line, matches); err != nil {
+			return summary, err
+		}
+	}
+
+	if err = lines.Err(); err != nil {
+		_ = output.Flush()
+		return summary, err
+	}
+
+	return summary, output.Flush()
+}
+
+// write writes the line when the output selects it; annotated lines are prefixed with the matching sources and
+// their rules as tab-separated columns ("tor,cloud<TAB>10.0.0.0/8,10.1.0.0/16<TAB>line"), or "-" for none.
+func (this *Scanner) write(output *bufio.Writer, line []byte, matches []ipfilter.SourceMatch) (err error) {
+	switch this.config.output {
+	case Matching:
+		if len(matches) == 0 {
+			return nil
+		}
+	case NonMatching:
+		if len(matches) > 0 {
+			return nil
+		}
+	case Annotated:
+		_, err = output.WriteString(annotation(matches))
+	}
+
+	if err == nil {
+		_, err = output.Write(line)
+	}
+	if err == nil {
+		err = output.WriteByte('\n')
+	}
+	return err
+}
+func annotation(matches []ipfilter.SourceMatch) string {
+	if len(matches) == 0 {
+		return "-\t-\t"
+	}
+
+	var names, prefixes strings.Builder
+	for i, match := range matches {
+		if i > 0 {
+			names.WriteByte(',')
+			prefixes.WriteByte(',')
+		}
+		names.WriteString(match.Name)
+		prefixes.WriteString(match.Prefix.String())
+	}
+
+	return names.String() + "\t" + prefixes.String() + "\t"
+}
+
+// Output selects the lines that a scan writes.
+type Output int
+
+const (
+	Matching    Output = iota // lines whose address matched a source
+	NonMatching               // every other line, including lines without an address
+	Annotated                 // every line, prefixed with the matching sources and rules
+)
+
+const maximumLineLength = 1 << 20
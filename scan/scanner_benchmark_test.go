@@ -0,0 +1,40 @@
+package scan
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/smarty/ip-filter"
+)
+
+func BenchmarkScan(b *testing.B) {
+	matcher := ipfilter.NewComposite()
+	matcher.Set("tor", "10.0.0.0/8", "2600:f0f0::/32")
+
+	for _, format := range []struct {
+		name    string
+		extract Extractor
+		line    string
+	}{
+		{name: "combined", extract: Combined(),
+			line: `%s - - [19/Oct/2026:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326 "-" "curl/8.0"`},
+		{name: "json", extract: JSON("remote_addr"),
+			line: `{"time": "2026-10-19T13:55:36Z", "remote_addr": "%s", "request": "GET /index.html", "status": 200}`},
+	} {
+		var log strings.Builder
+		for i := 0; i < 10000; i++ {
+			fmt.Fprintf(&log, format.line+"\n", fmt.Sprintf("%d.%d.%d.%d", i%24+1, i%251, i%253, i%241))
+		}
+		content := log.String()
+
+		b.Run(format.name, func(b *testing.B) {
+			scanner := New(matcher, Options.Format(format.extract), Options.Output(Annotated))
+			b.SetBytes(int64(len(content)))
+			for i := 0; i < b.N; i++ {
+				_, _ = scanner.Scan(strings.NewReader(content), io.Discard)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package scan
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/smarty/ip-filter"
+)
+
+const accessLog = `10.1.2.3 - - [19/Oct/2026:13:55:36 -0700] "GET / HTTP/1.1" 200 2326
+8.8.8.8 - - [19/Oct/2026:13:55:37 -0700] "GET / HTTP/1.1" 200 2326
+garbage
+2600:f0f0::1 - - [19/Oct/2026:13:55:38 -0700] "GET / HTTP/1.1" 404 0
+`
+
+func TestScanOutputs(t *testing.T) {
+	matcher := ipfilter.NewComposite()
+	matcher.Set("tor", "10.0.0.0/8")
+	matcher.Set("cloud", "10.1.0.0/16", "2600:f0f0::/32")
+
+	lines := strings.Split(accessLog, "\n")
+	for _, test := range []struct {
+		output   Output
+		expected string
+	}{
+		{output: Matching, expected: lines[0] + "\n" + lines[3] + "\n"},
+		{output: NonMatching, expected: lines[1] + "\n" + lines[2] + "\n"},
+		{output: Annotated, expected: "tor,cloud\t10.0.0.0/8,10.1.0.0/16\t" + lines[0] + "\n" +
+			"-\t-\t" + lines[1] + "\n" +
+			"-\t-\t" + lines[2] + "\n" +
+			"cloud\t2600:f0f0::/32\t" + lines[3] + "\n"},
+	} {
+		var output bytes.Buffer
+		summary, err := New(matcher, Options.Output(test.output)).Scan(strings.NewReader(accessLog), &output)
+
+		Assert(t).That(err).Equals(nil)
+		Assert(t).That(summary).Equals(Summary{Lines: 4, Matched: 2, Unparsed: 1})
+		Assert(t).That(output.String()).Equals(test.expected)
+	}
+}
+func TestMatch(t *testing.T) {
+	matcher := ipfilter.NewComposite()
+	matcher.Set("tor", "10.0.0.0/8")
+	scanner := New(matcher, Options.Format(JSON("ip")))
+
+	matches, parsed := scanner.Match([]byte(`{"ip": "10.1.2.3"}`))
+	Assert(t).That(parsed).Equals(true)
+	Assert(t).That(matches).Equals([]ipfilter.SourceMatch{{Name: "tor", Prefix: netip.MustParsePrefix("10.0.0.0/8")}})
+
+	matches, parsed = scanner.Match([]byte(`{"ip": "8.8.8.8"}`))
+	Assert(t).That([]any{len(matches), parsed}).Equals([]any{0, true})
+
+	matches, parsed = scanner.Match([]byte(`{}`))
+	Assert(t).That([]any{len(matches), parsed}).Equals([]any{0, false})
+}
+func TestScanStopsWhenWritingFails(t *testing.T) {
+	matcher := ipfilter.NewComposite()
+	matcher.Set("tor", "10.0.0.0/8")
+	line := "10.1.2.3 - - " + strings.Repeat("x", 1024) + "\n"
+
+	summary, err := New(matcher).Scan(strings.NewReader(strings.Repeat(line, 1000)), failingWriter{})
+	Assert(t).That(err).Equals(errWrite)
+	Assert(t).That(summary.Lines < 1000).Equals(true)
+}
+func TestScanFailsOnOverlongLines(t *testing.T) {
+	_, err := New(ipfilter.NewComposite()).Scan(strings.NewReader(strings.Repeat("x", maximumLineLength+1)), &bytes.Buffer{})
+	Assert(t).That(err != nil).Equals(true)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errWrite }
+
+var errWrite = errors.New("write failed")
+
+type That struct{ t *testing.T }
+type Assertion struct {
+	*testing.T
+	actual interface{}
+}
+
+func Assert(t *testing.T) *That                       { return &That{t: t} }
+func (this *That) That(actual interface{}) *Assertion { return &Assertion{T: this.t, actual: actual} }
+
+func (this *Assertion) Equals(expected interface{}) {
+	this.Helper()
+	if !reflect.DeepEqual(this.actual, expected) {
+		this.Errorf("\nExpected: %#v\nActual:   %#v", expected, this.actual)
+	}
+}
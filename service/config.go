@@ -0,0 +1,54 @@
+package service
+
+import (
+	"log/slog"
+	"time"
+)
+
+type configuration struct {
+	logger       *slog.Logger
+	watch        time.Duration
+	maximumBatch int
+}
+
+var Options singleton
+
+type singleton struct{}
+type option func(*configuration)
+
+// Logger logs configuration reloads and failures, and is passed to the reloaders of files and feeds (default: no
+// logging).
+func (singleton) Logger(value *slog.Logger) option {
+	return func(this *configuration) { this.logger = value }
+}
+
+// Watch makes Run check the configuration file for changes every interval and reload it when its size or
+// modification time changes (default: 0, only Reload reloads it). Rule files and feeds follow the reload interval of
+// their source either way.
+func (singleton) Watch(interval time.Duration) option {
+	return func(this *configuration) { this.watch = interval }
+}
+
+// MaximumBatch sets how many addresses one POST /check may hold (default: 1000, also used for zero).
+func (singleton) MaximumBatch(value int) option {
+	return func(this *configuration) { this.maximumBatch = value }
+}
+
+func (singleton) apply(options ...option) option {
+	return func(this *configuration) {
+		for _, item := range Options.defaults(options...) {
+			item(this)
+		}
+
+		if this.maximumBatch <= 0 {
+			this.maximumBatch = defaultMaximumBatch
+		}
+	}
+}
+func (singleton) defaults(options ...option) []option {
+	return append([]option{
+		Options.MaximumBatch(defaultMaximumBatch),
+	}, options...)
+}
+
+const defaultMaximumBatch = 1000
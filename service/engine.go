@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/smarty/ip-filter"
+	"github.com/smarty/ip-filter/config"
+)
+
+// generation is one build of the configuration.
+type generation struct {
+	engine *config.Engine
+	number uint64
+	loaded time.Time
+}
+
+// failure is the outcome of a reload that kept the previous generation.
+type failure struct {
+	err    error
+	failed time.Time
+}
+
+type fileStamp struct {
+	size     int64
+	modified time.Time
+}
+
+// Reload reads and builds the configuration file again and swaps the new engine in. When that fails the current
+// engine keeps answering, and the error is returned and reported by /health until a reload succeeds.
+func (this *Handler) Reload() error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if err := this.load(); err != nil {
+		this.failure.Store(&failure{err: err, failed: time.Now()})
+		if this.config.logger != nil {
+			this.config.logger.Warn("ipfilter: configuration reload failed, keeping previous configuration",
+				slog.String("path", this.path), slog.String("error", err.Error()))
+		}
+		return err
+	}
+
+	this.failure.Store(nil)
+	select {
+	case this.swapped <- struct{}{}:
+	default: // Run has yet to pick up an earlier swap, which will start the current engine anyway
+	}
+
+	if this.config.logger != nil {
+		current := this.current.Load()
+		this.config.logger.Info("ipfilter: configuration reloaded",
+			slog.String("path", this.path), slog.Uint64("generation", current.number),
+			slog.Int("rules", current.engine.Stats().Rules()))
+	}
+
+	return nil
+}
+
+// load builds a new generation; callers hold the lock.
+func (this *Handler) load() error {
+	this.stamp = stampOf(this.path)
+
+	settings, err := config.Load(this.path)
+	if err != nil {
+		return err
+	}
+
+	engine, err := config.Build(settings, config.Options.Logger(this.config.logger))
+	if err != nil {
+		return err
+	}
+
+	number := uint64(1)
+	if previous := this.current.Load(); previous != nil {
+		number = previous.number + 1
+	}
+	this.current.Store(&generation{engine: engine, number: number, loaded: time.Now()})
+	return nil
+}
+
+// Run keeps the current engine's files and feeds up to date and, with Options.Watch, reloads the configuration file
+// when it changes, until ctx is cancelled.
+func (this *Handler) Run(ctx context.Context) {
+	var waiter sync.WaitGroup
+	defer waiter.Wait()
+
+	if this.config.watch > 0 {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+			this.watch(ctx)
+		}()
+	}
+
+	for {
+		running, cancel := context.WithCancel(ctx)
+		stopped := make(chan struct{})
+		go func(engine *config.Engine) {
+			defer close(stopped)
+			engine.Run(running)
+		}(this.current.Load().engine)
+
+		select {
+		case <-ctx.Done():
+		case <-this.swapped:
+		}
+
+		cancel()
+		<-stopped
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+func (this *Handler) watch(ctx context.Context) {
+	ticker := time.NewTicker(this.config.watch)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if this.changed() {
+			_ = this.Reload()
+		}
+	}
+}
+func (this *Handler) changed() bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return stampOf(this.path) != this.stamp
+}
+func stampOf(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{size: -1}
+	}
+
+	return fileStamp{size: info.Size(), modified: info.ModTime()}
+}
+
+// Contains reports whether the current engine denies the address, so that a Handler can be used as a Filter.
+func (this *Handler) Contains(ipAddress string) bool {
+	return this.current.Load().engine.Contains(ipAddress)
+}
+func (this *Handler) ContainsAddr(address netip.Addr) bool {
+	return this.current.Load().engine.ContainsAddr(address)
+}
+
+func (this *Handler) Stats() ipfilter.Stats { return this.current.Load().engine.Stats() }
+
+// Generation returns the number of the current configuration build, starting at 1, and when it was loaded.
+func (this *Handler) Generation() (uint64, time.Time) {
+	current := this.current.Load()
+	return current.number, current.loaded
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/smarty/ip-filter/metrics"
+)
+
+// Handler answers lookups against the sources of a configuration file (see package config):
+//
+//	GET  /check?ip=10.1.2.3                one address
+//	POST /check {"ips": ["10.1.2.3", ...]} many addresses
+//	GET  /health                           the sources, the configuration generation and the last reload error
+//	GET  /metrics                          lookups, matches and rule counts in the Prometheus text format
+//
+// Call Run to keep files and feeds up to date, and Reload (on SIGHUP, say) to read the configuration file again.
+type Handler struct {
+	path     string
+	config   configuration
+	lock     sync.Mutex // serializes reloads
+	stamp    fileStamp
+	current  atomic.Pointer[generation]
+	failure  atomic.Pointer[failure]
+	swapped  chan struct{}
+	lookups  *metrics.Instrumented
+	exporter *metrics.Exporter
+	mux      *http.ServeMux
+}
+
+// New loads the configuration file at path; unlike later reloads, the first load must succeed.
+func New(path string, options ...option) (*Handler, error) {
+	this := &Handler{path: path, swapped: make(chan struct{}, 1), exporter: metrics.New(), mux: http.NewServeMux()}
+	Options.apply(options...)(&this.config)
+
+	if err := this.load(); err != nil {
+		return nil, err
+	}
+
+	this.lookups = this.exporter.Instrument("ipfilter", this)
+	this.mux.HandleFunc("GET /check", this.checkOne)
+	this.mux.HandleFunc("POST /check", this.checkMany)
+	this.mux.HandleFunc("GET /health", this.health)
+	this.mux.Handle("GET /metrics", this.exporter)
+	return this, nil
+}
+
+func (this *Handler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	this.mux.ServeHTTP(response, request)
+}
+
+// Result is the answer for one address. Action is the configured action ("allow", "deny" or "log"), which is the
+// default action when no source matched. Source and Prefix name the deciding source and its most specific rule
+// containing the address; Sources lists every enabled source containing it.
+type Result struct {
+	IP      string   `json:"ip"`
+	Action  string   `json:"action,omitempty"`
+	Matched bool     `json:"matched"`
+	Source  string   `json:"source,omitempty"`
+	Prefix  string   `json:"prefix,omitempty"`
+	Sources []string `json:"sources,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Batch is the body of POST /check, and Results its answer, in the same order.
+type Batch struct {
+	IPs []string `json:"ips"`
+}
+type Results struct {
+	Results []Result `json:"results"`
+}
+
+func (this *Handler) evaluate(text string) Result {
+	address, err := netip.ParseAddr(text)
+	if err != nil {
+		return Result{IP: text, Error: fmt.Sprintf("%s: %q", errInvalidAddress, text)}
+	}
+
+	verdict := this.current.Load().engine.Evaluate(address)
+	result := Result{IP: text, Action: verdict.Action.String(), Matched: len(verdict.Matched) > 0}
+	if result.Matched {
+		result.Source, result.Prefix, result.Sources = verdict.Source, verdict.Prefix.String(), verdict.Matched
+	}
+
+	this.lookups.Observe(result.Matched)
+	return result
+}
+
+func (this *Handler) checkOne(response http.ResponseWriter, request *http.Request) {
+	text := request.URL.Query().Get("ip")
+	if len(text) == 0 {
+		writeError(response, http.StatusBadRequest, errMissingAddress)
+		return
+	}
+
+	result := this.evaluate(text)
+	if len(result.Error) > 0 {
+		writeJSON(response, http.StatusBadRequest, result)
+		return
+	}
+
+	writeJSON(response, http.StatusOK, result)
+}
+
+// checkMany answers every address of the batch; invalid addresses get a Result with an Error rather than failing
+// the whole batch.
+func (this *Handler) checkMany(response http.ResponseWriter, request *http.Request) {
+	var batch Batch
+	body := http.MaxBytesReader(response, request.Body, int64(this.config.maximumBatch)*bytesPerAddress+1024)
+
+	if err := json.NewDecoder(body).Decode(&batch); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(response, http.StatusRequestEntityTooLarge, errBatchTooLarge)
+		} else {
+			writeError(response, http.StatusBadRequest, fmt.Errorf("%w: %w", errMalformedBatch, err))
+		}
+		return
+	}
+
+	if len(batch.IPs) > this.config.maximumBatch {
+		writeError(response, http.StatusRequestEntityTooLarge, errBatchTooLarge)
+		return
+	}
+
+	results := Results{Results: make([]Result, 0, len(batch.IPs))}
+	for _, text := range batch.IPs {
+		results.Results = append(results.Results, this.evaluate(text))
+	}
+
+	writeJSON(response, http.StatusOK, results)
+}
+
+// Health is the answer of GET /health. Status is "degraded" while the last reload of the configuration file failed
+// and the previous configuration is still in use.
+type Health struct {
+	Status     string         `json:"status"`
+	Generation uint64         `json:"generation"`
+	Loaded     time.Time      `json:"loaded"`
+	Sources    []HealthSource `json:"sources"`
+	Error      string         `json:"error,omitempty"`
+	Failed     *time.Time     `json:"failed,omitempty"`
+}
+type HealthSource struct {
+	Name    string    `json:"name"`
+	Enabled bool      `json:"enabled"`
+	Rules   int       `json:"rules"`
+	Updated time.Time `json:"updated"`
+}
+
+func (this *Handler) health(response http.ResponseWriter, _ *http.Request) {
+	current := this.current.Load()
+	health := Health{Status: "ok", Generation: current.number, Loaded: current.loaded}
+
+	for _, source := range current.engine.Composite().Sources() {
+		health.Sources = append(health.Sources, HealthSource{
+			Name: source.Name, Enabled: source.Enabled, Rules: source.Rules, Updated: source.Updated,
+		})
+	}
+
+	if failed := this.failure.Load(); failed != nil {
+		health.Status, health.Error, health.Failed = "degraded", failed.err.Error(), &failed.failed
+	}
+
+	writeJSON(response, http.StatusOK, health)
+}
+
+func writeError(response http.ResponseWriter, status int, err error) {
+	writeJSON(response, status, struct {
+		Error string `json:"error"`
+	}{Error: err.Error()})
+}
+func writeJSON(response http.ResponseWriter, status int, value any) {
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(status)
+	_ = json.NewEncoder(response).Encode(value)
+}
+
+var (
+	errMissingAddress = errors.New("missing ip parameter")
+	errInvalidAddress = errors.New("invalid address")
+	errMalformedBatch = errors.New(`malformed batch (expected {"ips": [...]})`)
+	errBatchTooLarge  = errors.New("batch too large")
+)
+
+const bytesPerAddress = 64 // generous for a quoted IPv6 address with a zone
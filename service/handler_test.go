@@ -0,0 +1,237 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smarty/ip-filter/internal/testfiles"
+)
+
+const configuration1 = `{"sources": [
+	{"name": "tor", "rules": ["10.0.0.0/8", "10.1.0.0/16"]},
+	{"name": "office", "action": "allow", "priority": 10, "rules": ["10.1.2.0/24"]},
+	{"name": "cloud", "action": "log", "rules": ["2600:f0f0::/32"]}
+]}`
+
+func TestCheckOne(t *testing.T) {
+	handler := newHandler(t, configuration1)
+
+	status, body := serve(handler, http.MethodGet, "/check?ip=10.1.9.9", "")
+	Assert(t).That(status).Equals(http.StatusOK)
+	Assert(t).That(decode[Result](t, body)).Equals(Result{
+		IP: "10.1.9.9", Action: "deny", Matched: true, Source: "tor", Prefix: "10.1.0.0/16", Sources: []string{"tor"}})
+
+	status, body = serve(handler, http.MethodGet, "/check?ip=10.1.2.3", "")
+	Assert(t).That(status).Equals(http.StatusOK)
+	Assert(t).That(decode[Result](t, body)).Equals(Result{
+		IP: "10.1.2.3", Action: "allow", Matched: true, Source: "office", Prefix: "10.1.2.0/24",
+		Sources: []string{"tor", "office"}})
+
+	status, body = serve(handler, http.MethodGet, "/check?ip=8.8.8.8", "")
+	Assert(t).That(status).Equals(http.StatusOK)
+	Assert(t).That(body).Equals(`{"ip":"8.8.8.8","action":"allow","matched":false}` + "\n")
+}
+func TestCheckOneFailures(t *testing.T) {
+	handler := newHandler(t, configuration1)
+
+	status, body := serve(handler, http.MethodGet, "/check", "")
+	Assert(t).That(status).Equals(http.StatusBadRequest)
+	Assert(t).That(body).Equals(`{"error":"missing ip parameter"}` + "\n")
+
+	status, body = serve(handler, http.MethodGet, "/check?ip=bogus", "")
+	Assert(t).That(status).Equals(http.StatusBadRequest)
+	Assert(t).That(decode[Result](t, body)).Equals(Result{IP: "bogus", Error: `invalid address: "bogus"`})
+
+	status, _ = serve(handler, http.MethodPut, "/check?ip=10.1.2.3", "")
+	Assert(t).That(status).Equals(http.StatusMethodNotAllowed)
+}
+func TestCheckMany(t *testing.T) {
+	handler := newHandler(t, configuration1)
+
+	status, body := serve(handler, http.MethodPost, "/check", `{"ips": ["10.1.9.9", "bogus", "2600:f0f0::1", "8.8.8.8"]}`)
+	Assert(t).That(status).Equals(http.StatusOK)
+	Assert(t).That(decode[Results](t, body)).Equals(Results{Results: []Result{
+		{IP: "10.1.9.9", Action: "deny", Matched: true, Source: "tor", Prefix: "10.1.0.0/16", Sources: []string{"tor"}},
+		{IP: "bogus", Error: `invalid address: "bogus"`},
+		{IP: "2600:f0f0::1", Action: "log", Matched: true, Source: "cloud", Prefix: "2600:f0f0::/32",
+			Sources: []string{"cloud"}},
+		{IP: "8.8.8.8", Action: "allow"},
+	}})
+
+	status, body = serve(handler, http.MethodPost, "/check", `{"ips": []}`)
+	Assert(t).That(status).Equals(http.StatusOK)
+	Assert(t).That(body).Equals(`{"results":[]}` + "\n")
+}
+func TestCheckManyFailures(t *testing.T) {
+	handler := newHandler(t, configuration1, Options.MaximumBatch(2))
+
+	status, body := serve(handler, http.MethodPost, "/check", `["10.1.2.3"]`)
+	Assert(t).That(status).Equals(http.StatusBadRequest)
+	Assert(t).That(strings.HasPrefix(body, `{"error":"malformed batch (expected {\"ips\": [...]}): `)).Equals(true)
+
+	status, body = serve(handler, http.MethodPost, "/check", `{"ips": ["10.0.0.1", "10.0.0.2", "10.0.0.3"]}`)
+	Assert(t).That(status).Equals(http.StatusRequestEntityTooLarge)
+	Assert(t).That(body).Equals(`{"error":"batch too large"}` + "\n")
+
+	status, _ = serve(handler, http.MethodPost, "/check", `{"ips": ["`+strings.Repeat("1", 4096)+`"]}`)
+	Assert(t).That(status).Equals(http.StatusRequestEntityTooLarge)
+}
+func TestReloadSwapsConfiguration(t *testing.T) {
+	path := testfiles.Write(t, t.TempDir(), "config.json", configuration1, 1)
+	handler, err := New(path)
+	Assert(t).That(err).Equals(nil)
+
+	testfiles.Write(t, filepath.Dir(path), "config.json", `{"default": "deny", "sources": [{"name": "tor", "rules": ["11.0.0.0/8"]}]}`, 2)
+	Assert(t).That(handler.Reload()).Equals(nil)
+
+	_, body := serve(handler, http.MethodGet, "/check?ip=10.1.9.9", "")
+	Assert(t).That(decode[Result](t, body)).Equals(Result{IP: "10.1.9.9", Action: "deny"})
+	Assert(t).That(handler.Contains("11.1.2.3")).Equals(true)
+
+	_, body = serve(handler, http.MethodGet, "/health", "")
+	health := decode[Health](t, body)
+	Assert(t).That(health.Status).Equals("ok")
+	Assert(t).That(health.Generation).Equals(uint64(2))
+	Assert(t).That(len(health.Sources)).Equals(1)
+	Assert(t).That([]any{health.Sources[0].Name, health.Sources[0].Enabled, health.Sources[0].Rules}).
+		Equals([]any{"tor", true, 1})
+}
+func TestFailedReloadKeepsConfiguration(t *testing.T) {
+	path := testfiles.Write(t, t.TempDir(), "config.json", configuration1, 1)
+	handler, err := New(path)
+	Assert(t).That(err).Equals(nil)
+
+	testfiles.Write(t, filepath.Dir(path), "config.json", `{"sources": [{"name": "tor", "rules": ["10.0.0.0/33"]}]}`, 2)
+	Assert(t).That(handler.Reload() != nil).Equals(true)
+	Assert(t).That(handler.Contains("10.1.9.9")).Equals(true)
+
+	_, body := serve(handler, http.MethodGet, "/health", "")
+	health := decode[Health](t, body)
+	Assert(t).That(health.Status).Equals("degraded")
+	Assert(t).That(health.Generation).Equals(uint64(1))
+	Assert(t).That(strings.Contains(health.Error, `sources[0].rules[0]: invalid subnet bits: "10.0.0.0/33"`)).Equals(true)
+	Assert(t).That(health.Failed != nil).Equals(true)
+
+	testfiles.Write(t, filepath.Dir(path), "config.json", configuration1, 3)
+	Assert(t).That(handler.Reload()).Equals(nil)
+	_, body = serve(handler, http.MethodGet, "/health", "")
+	Assert(t).That(decode[Health](t, body).Status).Equals("ok")
+}
+func TestNewFailsOnInvalidConfiguration(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "missing.json"))
+	Assert(t).That(err != nil).Equals(true)
+
+	_, err = New(testfiles.Write(t, t.TempDir(), "config.json", `{"sources": []}`, 1))
+	Assert(t).That(err != nil).Equals(true)
+}
+func TestMetrics(t *testing.T) {
+	handler := newHandler(t, configuration1)
+	serve(handler, http.MethodGet, "/check?ip=10.1.9.9", "")
+	serve(handler, http.MethodPost, "/check", `{"ips": ["8.8.8.8", "2600:f0f0::1"]}`)
+
+	status, body := serve(handler, http.MethodGet, "/metrics", "")
+	Assert(t).That(status).Equals(http.StatusOK)
+	Assert(t).That(strings.Contains(body, `ipfilter_lookups_total{filter="ipfilter"} 3`)).Equals(true)
+	Assert(t).That(strings.Contains(body, `ipfilter_matches_total{filter="ipfilter"} 2`)).Equals(true)
+	Assert(t).That(strings.Contains(body, `ipfilter_reload_generation{filter="ipfilter"} 1`)).Equals(true)
+}
+func TestRunWatchesConfiguration(t *testing.T) {
+	directory := t.TempDir()
+	path := testfiles.Write(t, directory, "config.json", configuration1, 1)
+	handler, err := New(path, Options.Watch(time.Millisecond))
+	Assert(t).That(err).Equals(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		handler.Run(ctx)
+	}()
+
+	testfiles.Write(t, directory, "config.json", `{"sources": [{"name": "tor", "rules": ["11.0.0.0/8"]}]}`, 2)
+	for deadline := time.Now().Add(time.Second / 2); !handler.Contains("11.1.2.3") && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	Assert(t).That(handler.Contains("11.1.2.3")).Equals(true)
+
+	generation, _ := handler.Generation()
+	Assert(t).That(generation).Equals(uint64(2))
+
+	cancel()
+	<-finished
+}
+func TestRunReloadsRuleFilesOfTheCurrentConfiguration(t *testing.T) {
+	directory := t.TempDir()
+	rules := testfiles.Write(t, directory, "rules.txt", "10.0.0.0/8\n", 1)
+	path := testfiles.Write(t, directory, "config.json",
+		`{"sources": [{"name": "file", "files": [`+quote(rules)+`], "reload": "1ms"}]}`, 1)
+	handler, err := New(path)
+	Assert(t).That(err).Equals(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		handler.Run(ctx)
+	}()
+
+	Assert(t).That(handler.Reload()).Equals(nil) // Run follows the new engine
+	testfiles.Write(t, directory, "rules.txt", "11.0.0.0/8\n", 2)
+	for deadline := time.Now().Add(time.Second / 2); !handler.Contains("11.1.2.3") && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	Assert(t).That(handler.Contains("11.1.2.3")).Equals(true)
+	Assert(t).That(handler.Contains("10.1.2.3")).Equals(false)
+
+	cancel()
+	<-finished
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func newHandler(t *testing.T, content string, options ...option) *Handler {
+	handler, err := New(testfiles.Write(t, t.TempDir(), "config.json", content, 1), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handler
+}
+func serve(handler http.Handler, method, target, body string) (int, string) {
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+	return response.Code, response.Body.String()
+}
+func decode[T any](t *testing.T, body string) (value T) {
+	if err := json.Unmarshal([]byte(body), &value); err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+func quote(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
+
+type That struct{ t *testing.T }
+type Assertion struct {
+	*testing.T
+	actual interface{}
+}
+
+func Assert(t *testing.T) *That                       { return &That{t: t} }
+func (this *That) That(actual interface{}) *Assertion { return &Assertion{T: this.t, actual: actual} }
+
+func (this *Assertion) Equals(expected interface{}) {
+	this.Helper()
+	if !reflect.DeepEqual(this.actual, expected) {
+		this.Errorf("\nExpected: %#v\nActual:   %#v", expected, this.actual)
+	}
+}
@@ -0,0 +1,66 @@
+package special
+
+import "net/netip"
+
+// IPv4: https://www.iana.org/assignments/iana-ipv4-special-registry
+var (
+	thisNetwork          = entry("0.0.0.0/8", "This network", "RFC 791", false)
+	thisHost             = entry("0.0.0.0/32", "This host on this network", "RFC 1122", false)
+	private10            = entry("10.0.0.0/8", "Private-Use", "RFC 1918", false)
+	sharedAddressSpace   = entry("100.64.0.0/10", "Shared Address Space", "RFC 6598", false)
+	loopback4            = entry("127.0.0.0/8", "Loopback", "RFC 1122", false)
+	linkLocal4           = entry("169.254.0.0/16", "Link Local", "RFC 3927", false)
+	private172           = entry("172.16.0.0/12", "Private-Use", "RFC 1918", false)
+	protocolAssignments4 = entry("192.0.0.0/24", "IETF Protocol Assignments", "RFC 6890", false)
+	serviceContinuity    = entry("192.0.0.0/29", "IPv4 Service Continuity Prefix", "RFC 7335", false)
+	dummy4               = entry("192.0.0.8/32", "IPv4 dummy address", "RFC 7600", false)
+	portControl4         = entry("192.0.0.9/32", "Port Control Protocol Anycast", "RFC 7723", true)
+	turn4                = entry("192.0.0.10/32", "Traversal Using Relays around NAT Anycast", "RFC 8155", true)
+	nat64Discovery1      = entry("192.0.0.170/32", "NAT64/DNS64 Discovery", "RFC 8880", false)
+	nat64Discovery2      = entry("192.0.0.171/32", "NAT64/DNS64 Discovery", "RFC 8880", false)
+	documentation1       = entry("192.0.2.0/24", "Documentation (TEST-NET-1)", "RFC 5737", false)
+	as112v4              = entry("192.31.196.0/24", "AS112-v4", "RFC 7535", true)
+	amt4                 = entry("192.52.193.0/24", "AMT", "RFC 7450", true)
+	private192           = entry("192.168.0.0/16", "Private-Use", "RFC 1918", false)
+	directDelegation4    = entry("192.175.48.0/24", "Direct Delegation AS112 Service", "RFC 7534", true)
+	benchmarking4        = entry("198.18.0.0/15", "Benchmarking", "RFC 2544", false)
+	documentation2       = entry("198.51.100.0/24", "Documentation (TEST-NET-2)", "RFC 5737", false)
+	documentation3       = entry("203.0.113.0/24", "Documentation (TEST-NET-3)", "RFC 5737", false)
+	reserved             = entry("240.0.0.0/4", "Reserved", "RFC 1112", false)
+	limitedBroadcast     = entry("255.255.255.255/32", "Limited Broadcast", "RFC 919", false)
+)
+
+// IPv6: https://www.iana.org/assignments/iana-ipv6-special-registry
+var (
+	loopback6            = entry("::1/128", "Loopback Address", "RFC 4291", false)
+	unspecified6         = entry("::/128", "Unspecified Address", "RFC 4291", false)
+	mapped6              = entry("::ffff:0:0/96", "IPv4-mapped Address", "RFC 4291", false)
+	translation6         = entry("64:ff9b::/96", "IPv4-IPv6 Translation", "RFC 6052", true)
+	translationLocal6    = entry("64:ff9b:1::/48", "IPv4-IPv6 Translation", "RFC 8215", false)
+	discardOnly6         = entry("100::/64", "Discard-Only Address Block", "RFC 6666", false)
+	protocolAssignments6 = entry("2001::/23", "IETF Protocol Assignments", "RFC 2928", false)
+	portControl6         = entry("2001:1::1/128", "Port Control Protocol Anycast", "RFC 7723", true)
+	turn6                = entry("2001:1::2/128", "Traversal Using Relays around NAT Anycast", "RFC 8155", true)
+	serviceRegistration6 = entry("2001:1::3/128", "DNS-SD Service Registration Protocol Anycast", "RFC 9665", true)
+	benchmarking6        = entry("2001:2::/48", "Benchmarking", "RFC 5180", false)
+	amt6                 = entry("2001:3::/32", "AMT", "RFC 7450", true)
+	as112v6              = entry("2001:4:112::/48", "AS112-v6", "RFC 7535", true)
+	orchid2              = entry("2001:20::/28", "ORCHIDv2", "RFC 7343", true)
+	droneRemoteID6       = entry("2001:30::/28", "Drone Remote ID Protocol Entity Tags (DETs) Prefix", "RFC 9374", true)
+	documentation6       = entry("2001:db8::/32", "Documentation", "RFC 3849", false)
+	directDelegation6    = entry("2620:4f:8000::/48", "Direct Delegation AS112 Service", "RFC 7534", true)
+	documentation6New    = entry("3fff::/20", "Documentation", "RFC 9637", false)
+	segmentRouting6      = entry("5f00::/16", "Segment Routing (SRv6) SIDs", "RFC 9602", false)
+	uniqueLocal          = entry("fc00::/7", "Unique-Local", "RFC 4193", false)
+	linkLocal6           = entry("fe80::/10", "Link-Local Unicast", "RFC 4291", false)
+)
+
+// Multicast: https://www.iana.org/assignments/multicast-addresses and ipv6-multicast-addresses
+var (
+	multicast4 = entry("224.0.0.0/4", "Multicast", "RFC 5771", false)
+	multicast6 = entry("ff00::/8", "Multicast", "RFC 4291", false)
+)
+
+func entry(prefix, name, rfc string, global bool) Entry {
+	return Entry{Prefix: netip.MustParsePrefix(prefix), Name: name, RFC: rfc, Global: global}
+}
@@ -0,0 +1,188 @@
+// Package special lists the special-purpose address ranges of the IANA IPv4 and IPv6 Special-Purpose Address
+// Registries, with the multicast ranges, so that they need not be typed again. Deprecated entries (such as 6to4
+// relay anycast and ORCHID) and entries whose reachability IANA lists as N/A (Teredo and 6to4) are left out.
+package special
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// Entry is one range of a registry. Global reports whether IANA lists the range as globally reachable; multicast
+// ranges are not, as they are never unicast destinations.
+type Entry struct {
+	Prefix netip.Prefix
+	Name   string
+	RFC    string
+	Global bool
+}
+
+// Registry is a list of entries. It is a filter in its own right, comparing all 128 bits of IPv6 addresses but
+// scanning every entry; for hot paths, merge its Rules with other lists in any constructor of package ipfilter.
+type Registry []Entry
+
+// Private returns the private-use (RFC 1918) and unique-local ranges.
+func Private() Registry { return Registry{private10, private172, private192, uniqueLocal} }
+
+// Loopback returns 127.0.0.0/8 and ::1/128.
+func Loopback() Registry { return Registry{loopback4, loopback6} }
+
+// LinkLocal returns 169.254.0.0/16 (which holds cloud metadata services such as 169.254.169.254) and fe80::/10.
+func LinkLocal() Registry { return Registry{linkLocal4, linkLocal6} }
+
+// SharedAddressSpace returns 100.64.0.0/10, used by carrier-grade NAT.
+func SharedAddressSpace() Registry { return Registry{sharedAddressSpace} }
+
+// Documentation returns the ranges reserved for examples.
+func Documentation() Registry {
+	return Registry{documentation1, documentation2, documentation3, documentation6, documentation6New}
+}
+
+// Multicast returns 224.0.0.0/4 and ff00::/8.
+func Multicast() Registry { return Registry{multicast4, multicast6} }
+
+// Reserved returns 240.0.0.0/4 (reserved for future use) and the limited broadcast address.
+func Reserved() Registry { return Registry{reserved, limitedBroadcast} }
+
+// SpecialPurpose returns every entry of the IPv4 and IPv6 special-purpose registries, in registry order.
+func SpecialPurpose() Registry {
+	return Registry{
+		thisNetwork, thisHost, private10, sharedAddressSpace, loopback4, linkLocal4, private172, protocolAssignments4,
+		serviceContinuity, dummy4, portControl4, turn4, nat64Discovery1, nat64Discovery2, documentation1, as112v4,
+		amt4, private192, directDelegation4, benchmarking4, documentation2, documentation3, reserved, limitedBroadcast,
+
+		loopback6, unspecified6, mapped6, translation6, translationLocal6, discardOnly6, protocolAssignments6,
+		portControl6, turn6, serviceRegistration6, benchmarking6, amt6, as112v6, orchid2, droneRemoteID6,
+		documentation6, directDelegation6, documentation6New, segmentRouting6, uniqueLocal, linkLocal6,
+	}
+}
+
+// Bogons returns the ranges that should never be seen as the source or destination of traffic on the public
+// internet: the special-purpose entries that are not globally reachable, less the globally reachable entries nested
+// inside them (such as 192.0.0.9/32 inside 192.0.0.0/24), and the multicast ranges.
+func Bogons() Registry {
+	registry := SpecialPurpose()
+	return Join(registry.Local().Without(registry.Global()), Multicast())
+}
+
+// Join merges registries, keeping the first entry of each prefix.
+func Join(registries ...Registry) (joined Registry) {
+	for _, registry := range registries {
+		for _, entry := range registry {
+			if !slices.ContainsFunc(joined, func(other Entry) bool { return other.Prefix == entry.Prefix }) {
+				joined = append(joined, entry)
+			}
+		}
+	}
+	return joined
+}
+
+// Local returns the entries that are not globally reachable.
+func (this Registry) Local() (local Registry) {
+	for _, entry := range this {
+		if !entry.Global {
+			local = append(local, entry)
+		}
+	}
+	return local
+}
+
+// Global returns the entries that are globally reachable.
+func (this Registry) Global() (global Registry) {
+	for _, entry := range this {
+		if entry.Global {
+			global = append(global, entry)
+		}
+	}
+	return global
+}
+
+// Without removes the address space of other, splitting entries that contain part of it into the largest prefixes
+// that remain; the pieces keep the name, RFC and reachability of their entry.
+func (this Registry) Without(other Registry) (remaining Registry) {
+	for _, entry := range this {
+		pieces := []netip.Prefix{entry.Prefix}
+		for _, hole := range other {
+			var next []netip.Prefix
+			for _, piece := range pieces {
+				next = append(next, subtract(piece, hole.Prefix)...)
+			}
+			pieces = next
+		}
+
+		for _, piece := range pieces {
+			entry.Prefix = piece
+			remaining = append(remaining, entry)
+		}
+	}
+	return remaining
+}
+func subtract(prefix, hole netip.Prefix) []netip.Prefix {
+	if !prefix.Overlaps(hole) {
+		return []netip.Prefix{prefix}
+	}
+	if hole.Bits() <= prefix.Bits() {
+		return nil
+	}
+
+	lower, upper := halves(prefix)
+	return append(subtract(lower, hole), subtract(upper, hole)...)
+}
+func halves(prefix netip.Prefix) (netip.Prefix, netip.Prefix) {
+	bits := prefix.Bits()
+	raw := prefix.Addr().AsSlice()
+	raw[bits/8] |= 0x80 >> (bits % 8)
+	upper, _ := netip.AddrFromSlice(raw)
+	return netip.PrefixFrom(prefix.Addr(), bits+1), netip.PrefixFrom(upper, bits+1)
+}
+
+// Lookup returns the most specific entry containing the address; IPv4-mapped IPv6 addresses are unmapped first.
+func (this Registry) Lookup(address netip.Addr) (Entry, bool) {
+	address = address.Unmap()
+
+	found, matched := Entry{}, false
+	for _, entry := range this {
+		if entry.Prefix.Contains(address) && (!matched || entry.Prefix.Bits() > found.Prefix.Bits()) {
+			found, matched = entry, true
+		}
+	}
+
+	return found, matched
+}
+
+func (this Registry) Contains(ipAddress string) bool {
+	address, err := netip.ParseAddr(ipAddress)
+	return err == nil && this.ContainsAddr(address)
+}
+func (this Registry) ContainsAddr(address netip.Addr) bool {
+	_, matched := this.Lookup(address)
+	return matched
+}
+
+// Rules returns the prefixes as rules for the constructors of package ipfilter, to merge the registry with other
+// lists. Those filters key the first 64 bits of IPv6 addresses and cannot look up addresses whose first 64 bits (or
+// 32 for IPv4) are all zero, so IPv6 entries inside ::/64 (loopback, unspecified and IPv4-mapped) are left out,
+// longer IPv6 prefixes cover their whole /64, and 0.0.0.0/8 matches all but 0.0.0.0. Contains has none of these
+// limits.
+func (this Registry) Rules() (rules []string) {
+	for _, entry := range this {
+		address := entry.Prefix.Addr()
+
+		switch {
+		case address.Is6() && leadingZero(address):
+			continue
+		case address.Is4() && address.IsUnspecified():
+			if entry.Prefix.Bits() == 32 {
+				continue
+			}
+			address = address.Next() // the filters reject rules based on 0.0.0.0; the rule still covers the prefix
+		}
+
+		rules = append(rules, netip.PrefixFrom(address, entry.Prefix.Bits()).String())
+	}
+	return rules
+}
+func leadingZero(address netip.Addr) bool {
+	raw := address.As16()
+	return raw[0]|raw[1]|raw[2]|raw[3]|raw[4]|raw[5]|raw[6]|raw[7] == 0
+}
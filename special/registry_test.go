@@ -0,0 +1,134 @@
+package special
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/smarty/ip-filter"
+)
+
+func TestConstructors(t *testing.T) {
+	assertContains(t, Private(), "10.1.2.3", "172.31.255.255", "192.168.0.1", "fd00::1")
+	assertNotContains(t, Private(), "172.32.0.0", "8.8.8.8", "fe80::1")
+	assertContains(t, Loopback(), "127.0.0.1", "127.255.255.254", "::1", "::ffff:127.0.0.1")
+	assertNotContains(t, Loopback(), "::2", "128.0.0.1")
+	assertContains(t, LinkLocal(), "169.254.169.254", "fe80::1")
+	assertContains(t, SharedAddressSpace(), "100.64.0.1", "100.127.255.255")
+	assertNotContains(t, SharedAddressSpace(), "100.128.0.0")
+	assertContains(t, Documentation(), "192.0.2.1", "198.51.100.1", "203.0.113.1", "2001:db8::1", "3fff::1")
+	assertContains(t, Multicast(), "224.0.0.1", "239.255.255.255", "ff02::1")
+	assertContains(t, Reserved(), "240.0.0.1", "255.255.255.255")
+	assertNotContains(t, Reserved(), "223.255.255.255")
+}
+func TestBogons(t *testing.T) {
+	bogons := Bogons()
+	assertContains(t, bogons,
+		"0.0.0.0", "10.1.2.3", "100.64.0.1", "127.0.0.1", "169.254.169.254", "192.0.0.8", "192.0.2.1",
+		"198.18.0.1", "224.0.0.1", "255.255.255.255", "::", "::1", "::ffff:10.1.2.3", "2001:2::1", "2001:db8::1",
+		"fc00::1", "fe80::1", "ff02::1")
+	assertNotContains(t, bogons,
+		"8.8.8.8", "192.0.0.9", "192.31.196.1", "2001:4860:4860::8888", "2001:1::1", "2001:4:112::1", "64:ff9b::1")
+
+	for _, entry := range bogons {
+		Assert(t).That(entry.Global).Equals(false)
+	}
+}
+func TestLookupReturnsTheMostSpecificEntry(t *testing.T) {
+	registry := SpecialPurpose()
+
+	entry, found := registry.Lookup(netip.MustParseAddr("192.0.0.9"))
+	Assert(t).That(found).Equals(true)
+	Assert(t).That(entry).Equals(Entry{
+		Prefix: netip.MustParsePrefix("192.0.0.9/32"), Name: "Port Control Protocol Anycast", RFC: "RFC 7723", Global: true})
+
+	entry, _ = registry.Lookup(netip.MustParseAddr("192.0.0.100"))
+	Assert(t).That([]any{entry.Name, entry.RFC, entry.Global}).Equals([]any{"IETF Protocol Assignments", "RFC 6890", false})
+
+	entry, _ = registry.Lookup(netip.MustParseAddr("0.0.0.0"))
+	Assert(t).That(entry.Name).Equals("This host on this network")
+
+	entry, _ = registry.Lookup(netip.MustParseAddr("::ffff:10.1.2.3"))
+	Assert(t).That(entry.Name).Equals("Private-Use")
+
+	_, found = registry.Lookup(netip.MustParseAddr("8.8.8.8"))
+	Assert(t).That(found).Equals(false)
+	Assert(t).That(registry.Contains("not an address")).Equals(false)
+}
+func TestWithout(t *testing.T) {
+	registry := Registry{entry("10.0.0.0/8", "Private-Use", "RFC 1918", false), entry("fe80::/10", "Link-Local", "", false)}
+	remaining := registry.Without(Registry{
+		entry("10.0.0.0/9", "", "", true), entry("10.192.0.0/10", "", "", true), entry("192.168.0.0/16", "", "", true),
+		entry("fe80::1/128", "", "", true),
+	})
+
+	Assert(t).That(remaining[0]).Equals(entry("10.128.0.0/10", "Private-Use", "RFC 1918", false))
+	Assert(t).That(len(remaining)).Equals(1 + 128 - 10) // one sibling for every bit below fe80::/10
+	Assert(t).That(remaining[1].Prefix).Equals(netip.MustParsePrefix("fe80::/128"))
+	Assert(t).That(remaining[1].Name).Equals("Link-Local")
+	assertContains(t, remaining, "10.128.0.1", "10.191.255.255", "fe80::", "fe80::2", "febf::1")
+	assertNotContains(t, remaining, "10.0.0.1", "10.127.255.255", "10.192.0.0", "fe80::1")
+
+	Assert(t).That(len(registry.Without(Registry{entry("0.0.0.0/0", "", "", true), entry("::/0", "", "", true)}))).
+		Equals(0)
+}
+func TestJoin(t *testing.T) {
+	joined := Join(Private(), Loopback(), Private(), Registry{entry("10.0.0.0/8", "Office", "", false)})
+	Assert(t).That(len(joined)).Equals(6)
+	Assert(t).That(joined[0].Name).Equals("Private-Use")
+}
+func TestEntriesAreMasked(t *testing.T) {
+	for _, entry := range Join(SpecialPurpose(), Multicast()) {
+		Assert(t).That(entry.Prefix).Equals(entry.Prefix.Masked())
+		Assert(t).That(len(entry.Name) > 0 && len(entry.RFC) > 0).Equals(true)
+	}
+}
+func TestRulesComposeWithFilters(t *testing.T) {
+	rules := Bogons().Rules()
+	for _, rule := range rules {
+		_, err := ipfilter.ParseRule(rule)
+		Assert(t).That(err).Equals(nil)
+	}
+
+	filter := ipfilter.NewCompressed(append(rules, "8.8.8.0/24")...)
+	assertContains(t, filter, "0.1.2.3", "10.1.2.3", "169.254.169.254", "8.8.8.8", "fe80::1", "2001:db8::1")
+	assertNotContains(t, filter, "8.8.4.4", "2001:4860:4860::8888")
+
+	Assert(t).That(Registry{thisNetwork, thisHost, loopback6, unspecified6, mapped6, portControl6}.Rules()).
+		Equals([]string{"0.0.0.1/8", "2001:1::1/128"})
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func assertContains(t *testing.T, filter ipfilter.Filter, addresses ...string) {
+	t.Helper()
+	for _, address := range addresses {
+		if !filter.Contains(address) {
+			t.Errorf("expected %s to be contained", address)
+		}
+	}
+}
+func assertNotContains(t *testing.T, filter ipfilter.Filter, addresses ...string) {
+	t.Helper()
+	for _, address := range addresses {
+		if filter.Contains(address) {
+			t.Errorf("expected %s not to be contained", address)
+		}
+	}
+}
+
+type That struct{ t *testing.T }
+type Assertion struct {
+	*testing.T
+	actual interface{}
+}
+
+func Assert(t *testing.T) *That                       { return &That{t: t} }
+func (this *That) That(actual interface{}) *Assertion { return &Assertion{T: this.t, actual: actual} }
+
+func (this *Assertion) Equals(expected interface{}) {
+	this.Helper()
+	if !reflect.DeepEqual(this.actual, expected) {
+		this.Errorf("\nExpected: %#v\nActual:   %#v", expected, this.actual)
+	}
+}
@@ -0,0 +1,72 @@
+package ssrf
+
+import (
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/smarty/ip-filter"
+	"github.com/smarty/ip-filter/special"
+)
+
+type configuration struct {
+	filter   ipfilter.Filter
+	allow    ipfilter.Filter
+	callback func(address netip.AddrPort, blocked bool)
+	dialer   *net.Dialer
+}
+
+// New returns a Guard that refuses connections to the addresses of Options.Filter (default: special.Bogons()).
+func New(options ...option) *Guard {
+	var config configuration
+	Options.apply(options...)(&config)
+	return &Guard{config: config}
+}
+
+var Options singleton
+
+type singleton struct{}
+type option func(*configuration)
+
+// Filter sets the destinations to refuse (default: special.Bogons(), which covers loopback, private, link-local
+// (including 169.254.169.254), CGNAT and the other ranges that are not globally reachable). The filters of package
+// ipfilter cannot match addresses in ::/64, such as ::1, nor rules based at 0.0.0.0, such as 0.0.0.0/8; combine
+// them with a special.Registry, which can, using ipfilter.NewComposite or a filter of your own. The unspecified
+// addresses 0.0.0.0 and ::, which connect to the local host, are refused whatever the filters say.
+func (singleton) Filter(value ipfilter.Filter) option {
+	return func(this *configuration) { this.filter = value }
+}
+
+// Allow sets destinations to accept even though Filter contains them, such as an internal service that receives
+// webhooks (default: none).
+func (singleton) Allow(value ipfilter.Filter) option {
+	return func(this *configuration) { this.allow = value }
+}
+
+// Callback is invoked for every checked connection with its destination and whether it was refused; use it for
+// logging and counters.
+func (singleton) Callback(value func(address netip.AddrPort, blocked bool)) option {
+	return func(this *configuration) { this.callback = value }
+}
+
+// Dialer sets the dialer that Transport guards, such as one with its own Resolver (default: a 30 second timeout and
+// keep-alive, like http.DefaultTransport).
+func (singleton) Dialer(value *net.Dialer) option {
+	return func(this *configuration) { this.dialer = value }
+}
+
+func (singleton) apply(options ...option) option {
+	return func(this *configuration) {
+		for _, item := range Options.defaults(options...) {
+			item(this)
+		}
+	}
+}
+func (singleton) defaults(options ...option) []option {
+	return append([]option{
+		Options.Filter(special.Bogons()),
+		Options.Allow(nil),
+		Options.Callback(func(netip.AddrPort, bool) {}),
+		Options.Dialer(&net.Dialer{Timeout: time.Second * 30, KeepAlive: time.Second * 30}),
+	}, options...)
+}
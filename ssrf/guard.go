@@ -0,0 +1,120 @@
+// Package ssrf keeps outgoing connections away from internal destinations. It checks the address that is actually
+// being connected to, after DNS resolution, so that a hostname that resolves to a public address when validated and
+// to an internal one when used (DNS rebinding) is still refused.
+package ssrf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+
+	"github.com/smarty/ip-filter"
+)
+
+// Guard refuses connections to filtered destinations.
+type Guard struct {
+	config configuration
+}
+
+// Check returns a *BlockedError when the address is filtered and not allowed, and for unspecified and invalid
+// addresses in any case. IPv4-mapped IPv6 addresses are checked as IPv4, and zones are ignored.
+func (this *Guard) Check(address netip.Addr) error {
+	address = address.Unmap().WithZone("")
+
+	if address.IsUnspecified() || !address.IsValid() {
+		return &BlockedError{Address: address} // 0.0.0.0 and :: reach the local host, whatever the filters say
+	}
+	if this.config.allow != nil && ipfilter.ContainsAddr(this.config.allow, address) {
+		return nil
+	}
+	if ipfilter.ContainsAddr(this.config.filter, address) {
+		return &BlockedError{Address: address}
+	}
+
+	return nil
+}
+
+// Control is a net.Dialer Control hook; it runs for every address a dial attempts, after resolution and before the
+// connection is made. Destinations that are not IP addresses are refused.
+func (this *Guard) Control(network, address string, _ syscall.RawConn) error {
+	destination, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return &BlockedError{Network: network, reason: "not an IP destination: " + address}
+	}
+
+	err = this.Check(destination.Addr())
+	this.config.callback(destination, err != nil)
+
+	var blocked *BlockedError
+	if errors.As(err, &blocked) {
+		blocked.Network, blocked.Port = network, destination.Port()
+	}
+	return err
+}
+
+// Dialer returns a copy of base (or of a zero Dialer, when nil) that checks every destination with Control. A
+// Control or ControlContext hook already set on base runs after the check.
+func (this *Guard) Dialer(base *net.Dialer) *net.Dialer {
+	dialer := &net.Dialer{}
+	if base != nil {
+		*dialer = *base
+	}
+
+	inner, innerContext := dialer.Control, dialer.ControlContext
+	dialer.Control = nil
+	dialer.ControlContext = func(ctx context.Context, network, address string, conn syscall.RawConn) error {
+		if err := this.Control(network, address, conn); err != nil {
+			return err
+		}
+		if innerContext != nil {
+			return innerContext(ctx, network, address, conn)
+		}
+		if inner != nil {
+			return inner(network, address, conn)
+		}
+		return nil
+	}
+
+	return dialer
+}
+
+// Transport returns a clone of base (or of http.DefaultTransport, when nil) whose connections are made by the guarded
+// Options.Dialer. Proxies are disabled, since the guard would only see the address of the proxy; any DialContext or
+// DialTLSContext of base is replaced.
+func (this *Guard) Transport(base *http.Transport) *http.Transport {
+	if base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+
+	transport := base.Clone()
+	transport.Proxy = nil
+	transport.DialTLSContext, transport.DialTLS, transport.Dial = nil, nil, nil
+	transport.DialContext = this.Dialer(this.config.dialer).DialContext
+	return transport
+}
+
+// BlockedError reports a refused destination. It matches ErrBlocked with errors.Is, and can be found with errors.As
+// in the errors returned by dialers, transports and clients.
+type BlockedError struct {
+	Network string // such as "tcp4"; empty when returned by Check
+	Address netip.Addr
+	Port    uint16
+	reason  string
+}
+
+func (this *BlockedError) Error() string {
+	if len(this.reason) > 0 {
+		return fmt.Sprintf("%s (%s)", ErrBlocked, this.reason)
+	}
+	if this.Network == "" {
+		return fmt.Sprintf("%s: %s", ErrBlocked, this.Address)
+	}
+	return fmt.Sprintf("%s: %s %s", ErrBlocked, this.Network, netip.AddrPortFrom(this.Address, this.Port))
+}
+func (this *BlockedError) Unwrap() error { return ErrBlocked }
+
+var ErrBlocked = errors.New("ssrf: destination refused")
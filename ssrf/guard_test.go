@@ -0,0 +1,268 @@
+package ssrf
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/smarty/ip-filter"
+)
+
+func TestCheck(t *testing.T) {
+	guard := New()
+
+	for _, address := range []string{
+		"169.254.169.254", "10.1.2.3", "172.16.0.1", "192.168.1.1", "100.64.0.1", "127.0.0.1", "0.0.0.0", "::1", "::",
+		"::ffff:127.0.0.1", "::ffff:169.254.169.254", "fe80::1%eth0", "fd00:ec2::254", "224.0.0.1",
+	} {
+		err := guard.Check(netip.MustParseAddr(address))
+		var blocked *BlockedError
+		Assert(t).That(errors.As(err, &blocked)).Equals(true)
+		Assert(t).That(errors.Is(err, ErrBlocked)).Equals(true)
+		Assert(t).That(blocked.Address).Equals(netip.MustParseAddr(address).Unmap().WithZone(""))
+	}
+
+	for _, address := range []string{"8.8.8.8", "2001:4860:4860::8888", "192.0.0.9"} {
+		Assert(t).That(guard.Check(netip.MustParseAddr(address))).Equals(nil)
+	}
+
+	Assert(t).That(guard.Check(netip.MustParseAddr("169.254.169.254")).Error()).
+		Equals("ssrf: destination refused: 169.254.169.254")
+}
+func TestCheckWithFilters(t *testing.T) {
+	guard := New(Options.Filter(ipfilter.New("10.0.0.0/8")), Options.Allow(ipfilter.New("10.1.0.0/16")))
+
+	Assert(t).That(errors.Is(guard.Check(netip.MustParseAddr("10.2.0.1")), ErrBlocked)).Equals(true)
+	Assert(t).That(guard.Check(netip.MustParseAddr("10.1.0.1"))).Equals(nil)
+	Assert(t).That(guard.Check(netip.MustParseAddr("127.0.0.1"))).Equals(nil)
+
+	// a plain filter cannot hold rules based at 0.0.0.0, but the unspecified addresses are refused anyway
+	for _, address := range []string{"0.0.0.0", "::", "::ffff:0.0.0.0"} {
+		Assert(t).That(errors.Is(guard.Check(netip.MustParseAddr(address)), ErrBlocked)).Equals(true)
+	}
+	Assert(t).That(errors.Is(guard.Check(netip.Addr{}), ErrBlocked)).Equals(true)
+}
+func TestDialerRefusesFilteredDestinations(t *testing.T) {
+	server := listen(t)
+	var checked []string
+	guard := New(Options.Callback(func(address netip.AddrPort, blocked bool) {
+		checked = append(checked, address.String()+" "+map[bool]string{true: "blocked", false: "allowed"}[blocked])
+	}))
+	dialer := guard.Dialer(nil)
+
+	_, err := dialer.Dial("tcp", server)
+	var blocked *BlockedError
+	Assert(t).That(errors.As(err, &blocked)).Equals(true)
+	Assert(t).That(blocked.Network).Equals("tcp4")
+	Assert(t).That(netip.AddrPortFrom(blocked.Address, blocked.Port).String()).Equals(server)
+	Assert(t).That(blocked.Error()).Equals("ssrf: destination refused: tcp4 " + server)
+
+	_, err = dialer.Dial("tcp", "169.254.169.254:80") // refused before connecting
+	Assert(t).That(errors.Is(err, ErrBlocked)).Equals(true)
+
+	Assert(t).That(checked).Equals([]string{server + " blocked", "169.254.169.254:80 blocked"})
+}
+func TestDialerAllowsExceptionsAndChainsHooks(t *testing.T) {
+	server := listen(t)
+	var calls []string
+	guard := New(Options.Allow(ipfilter.New("127.0.0.1/32")))
+
+	base := &net.Dialer{Control: func(network, address string, _ syscall.RawConn) error {
+		calls = append(calls, "control "+network)
+		return nil
+	}}
+	conn, err := guard.Dialer(base).Dial("tcp", server)
+	Assert(t).That(err).Equals(nil)
+	_ = conn.Close()
+
+	base = &net.Dialer{ControlContext: func(_ context.Context, network, address string, _ syscall.RawConn) error {
+		calls = append(calls, "context "+network)
+		return errors.New("refused by the base dialer")
+	}}
+	_, err = guard.Dialer(base).Dial("tcp", server)
+	Assert(t).That(strings.Contains(err.Error(), "refused by the base dialer")).Equals(true)
+
+	Assert(t).That(calls).Equals([]string{"control tcp4", "context tcp4"})
+	Assert(t).That(base.Control == nil && base.ControlContext != nil).Equals(true) // the base is not modified
+}
+func TestControlRefusesNonIPDestinations(t *testing.T) {
+	err := New().Control("unix", "/var/run/docker.sock", nil)
+	Assert(t).That(errors.Is(err, ErrBlocked)).Equals(true)
+	Assert(t).That(err.Error()).Equals("ssrf: destination refused (not an IP destination: /var/run/docker.sock)")
+}
+func TestTransportChecksResolvedAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		response.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	resolver := newFakeResolver(t, map[string][]string{"hooks.example": {"127.0.0.1"}})
+	dialer := Options.Dialer(&net.Dialer{Resolver: resolver})
+
+	client := &http.Client{Transport: New(dialer).Transport(nil)}
+	_, err := client.Get("http://hooks.example:" + strconv.Itoa(port) + "/")
+	var blocked *BlockedError
+	Assert(t).That(errors.As(err, &blocked)).Equals(true)
+	Assert(t).That(blocked.Address).Equals(netip.MustParseAddr("127.0.0.1"))
+
+	client = &http.Client{Transport: New(dialer, Options.Allow(ipfilter.New("127.0.0.1/32"))).Transport(nil)}
+	response, err := client.Get("http://hooks.example:" + strconv.Itoa(port) + "/")
+	Assert(t).That(err).Equals(nil)
+	Assert(t).That(response.StatusCode).Equals(http.StatusNoContent)
+	_ = response.Body.Close()
+}
+func TestTransportRefusesRebinding(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Error("the request reached the internal server")
+	}))
+	defer server.Close()
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	// the first answer passes validation; the answer seen when connecting is internal
+	resolver := newFakeResolver(t, map[string][]string{"rebind.example": {"93.184.216.34", "127.0.0.1"}})
+	addresses, err := resolver.LookupHost(context.Background(), "rebind.example")
+	Assert(t).That(err).Equals(nil)
+	Assert(t).That(addresses).Equals([]string{"93.184.216.34"})
+
+	client := &http.Client{Transport: New(Options.Dialer(&net.Dialer{Resolver: resolver})).Transport(nil)}
+	_, err = client.Get("http://rebind.example:" + strconv.Itoa(port) + "/")
+	Assert(t).That(errors.Is(err, ErrBlocked)).Equals(true)
+}
+func TestTransportDisablesProxies(t *testing.T) {
+	base := &http.Transport{Proxy: http.ProxyFromEnvironment, MaxIdleConns: 7}
+	transport := New().Transport(base)
+
+	Assert(t).That(transport.Proxy == nil).Equals(true)
+	Assert(t).That(transport.DialContext != nil).Equals(true)
+	Assert(t).That(transport.MaxIdleConns).Equals(7)
+	Assert(t).That(base.Proxy != nil && base.DialContext == nil).Equals(true)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func listen(t *testing.T) string {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// newFakeResolver answers A queries for the names given, one answer per query until the last, which then repeats;
+// AAAA queries get no answers and other names do not exist.
+func newFakeResolver(t *testing.T, names map[string][]string) *net.Resolver {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	var lock sync.Mutex
+	answer := func(name string, queryType uint16) ([]byte, bool) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		addresses, found := names[name]
+		if !found || queryType != 1 {
+			return nil, found
+		}
+		if len(addresses) > 1 {
+			names[name] = addresses[1:]
+		}
+		return netip.MustParseAddr(addresses[0]).AsSlice(), true
+	}
+
+	go func() {
+		buffer := make([]byte, 512)
+		for {
+			size, peer, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			if response := respond(buffer[:size], answer); response != nil {
+				_, _ = conn.WriteTo(response, peer)
+			}
+		}
+	}()
+
+	return &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "udp4", conn.LocalAddr().String())
+	}}
+}
+func respond(query []byte, answer func(name string, queryType uint16) ([]byte, bool)) []byte {
+	var labels []string
+	end := 12
+	for end < len(query) && query[end] != 0 {
+		length := int(query[end])
+		if end+1+length > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[end+1:end+1+length]))
+		end += 1 + length
+	}
+	if end += 5; end > len(query) {
+		return nil
+	}
+
+	address, found := answer(strings.Join(labels, "."), binary.BigEndian.Uint16(query[end-4:]))
+
+	response := append([]byte{}, query[:2]...)                 // id
+	response = binary.BigEndian.AppendUint16(response, 0x8180) // a recursive answer
+	if !found {
+		response[3] |= 3 // name does not exist
+	}
+	response = binary.BigEndian.AppendUint16(response, 1) // questions
+	response = binary.BigEndian.AppendUint16(response, uint16(min(len(address), 1)))
+	response = append(response, 0, 0, 0, 0) // authority and additional records
+	response = append(response, query[12:end]...)
+
+	if len(address) > 0 {
+		response = append(response, 0xc0, 12)                  // the name of the question
+		response = binary.BigEndian.AppendUint16(response, 1)  // A
+		response = binary.BigEndian.AppendUint16(response, 1)  // IN
+		response = binary.BigEndian.AppendUint32(response, 60) // TTL
+		response = binary.BigEndian.AppendUint16(response, uint16(len(address)))
+		response = append(response, address...)
+	}
+
+	return response
+}
+
+type That struct{ t *testing.T }
+type Assertion struct {
+	*testing.T
+	actual interface{}
+}
+
+func Assert(t *testing.T) *That                       { return &That{t: t} }
+func (this *That) That(actual interface{}) *Assertion { return &Assertion{T: this.t, actual: actual} }
+
+func (this *Assertion) Equals(expected interface{}) {
+	this.Helper()
+	if !reflect.DeepEqual(this.actual, expected) {
+		this.Errorf("\nExpected: %#v\nActual:   %#v", expected, this.actual)
+	}
+}
@@ -0,0 +1,20 @@
+package ipfilter
+
+// Stats describes the size of a filter. Rules are counted after parsing, so invalid and duplicate rules are not
+// included; structures that drop rules nested inside other rules count only the rules they keep.
+type Stats struct {
+	IPv4Rules int
+	IPv6Rules int
+	Nodes     int // trie nodes, stride tables or intervals, depending on the structure
+	Bytes     int // estimated memory held by the structure
+}
+
+func (this Stats) Rules() int { return this.IPv4Rules + this.IPv6Rules }
+
+func (this *Stats) addRule(family int) {
+	if family == ipv4Child {
+		this.IPv4Rules++
+	} else {
+		this.IPv6Rules++
+	}
+}
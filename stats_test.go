@@ -0,0 +1,36 @@
+package ipfilter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	rules := []string{"10.0.0.0/8", "10.1.0.0/16", "10.0.0.0/8", "192.168.0.0/24", "2600:f0f0:2::/48", "junk"}
+
+	expiring := NewExpiring(nil)
+	for _, rule := range rules {
+		expiring.Add(rule, time.Time{})
+	}
+
+	for name, test := range map[string]struct {
+		filter interface{ Stats() Stats }
+		ipv4   int
+		ipv6   int
+		nodes  int
+	}{
+		"tree":       {filter: New(rules...).(*treeNode), ipv4: 3, ipv6: 1, nodes: 1 + 1 + 16 + 24 + 1 + 48},
+		"compressed": {filter: NewCompressed(rules...).(*compressedTree), ipv4: 2, ipv6: 1, nodes: 4},
+		"compiled":   {filter: NewCompiled(rules...), ipv4: 2, ipv6: 1, nodes: 2 + 5},
+		"flat":       {filter: NewFlat(rules...), ipv4: 2, ipv6: 1, nodes: 3},
+		"counting":   {filter: NewCounting(rules...), ipv4: 3, ipv6: 1, nodes: 5},
+		"expiring":   {filter: expiring, ipv4: 3, ipv6: 1, nodes: 2 + 16 + 24 + 48},
+	} {
+		t.Run(name, func(t *testing.T) {
+			stats := test.filter.Stats()
+			Assert(t).That([]int{stats.IPv4Rules, stats.IPv6Rules, stats.Nodes}).Equals([]int{test.ipv4, test.ipv6, test.nodes})
+			Assert(t).That(stats.Rules()).Equals(test.ipv4 + test.ipv6)
+			Assert(t).That(stats.Bytes > 0).Equals(true)
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package ipfilter
 
 import (
+	"encoding/binary"
+	"net/netip"
 	"strconv"
 	"strings"
+	"unsafe"
 )
 
 type treeNode struct {
@@ -26,33 +29,14 @@ func newNode() *treeNode {
 }
 
 func (this *treeNode) add(subnetMask string) {
-	if strings.Contains(subnetMask, ":") {
-		this.addIPv6(subnetMask)
-	} else {
-		this.addIPv4(subnetMask)
-	}
-}
-func (this *treeNode) addIPv4(subnetMask string) {
-	var numericIP uint32
-
-	subnetBits, baseIPAddress := prepareBaseIPAndSubnetMask(subnetMask)
-	if subnetBits == 0 || len(baseIPAddress) == 0 {
-		return
-	}
-
-	if !isNumeric(baseIPAddress) {
+	family, numericIP, subnetBits, ok := parseSubnetMask(subnetMask)
+	if !ok {
 		return
 	}
 
-	numericIP = parseIPv4Address(baseIPAddress)
-
-	if numericIP == 0 {
-		return
-	}
-
-	current := this.children[0]
+	current := this.children[family]
 	for i := 0; i < subnetBits; i++ {
-		nextBit := uint32(numericIP << i >> ipv4BitMask)
+		nextBit := numericIP << i >> numericBitMask
 		child := current.children[nextBit]
 
 		if child == nil {
@@ -64,41 +48,102 @@ func (this *treeNode) addIPv4(subnetMask string) {
 
 	current.banned = true
 }
-func (this *treeNode) addIPv6(subnetMask string) {
-	var numericIP uint64
 
+// parseSubnetMask returns the family, the base address left-aligned in a uint64 and the number of subnet bits.
+func parseSubnetMask(subnetMask string) (int, uint64, int, bool) {
+	if strings.Contains(subnetMask, ":") {
+		return parseIPv6SubnetMask(subnetMask)
+	} else {
+		return parseIPv4SubnetMask(subnetMask)
+	}
+}
+func parseIPv4SubnetMask(subnetMask string) (int, uint64, int, bool) {
 	subnetBits, baseIPAddress := prepareBaseIPAndSubnetMask(subnetMask)
-	if subnetBits == 0 || len(baseIPAddress) == 0 {
-		return
+	if subnetBits <= 0 || subnetBits > ipv4BitCount || len(baseIPAddress) == 0 {
+		return ipv4Child, 0, 0, false
 	}
 
-	if !containsValidHexValues(baseIPAddress) {
-		return
+	if !isNumeric(baseIPAddress) {
+		return ipv4Child, 0, 0, false
+	}
+
+	numericIP := parseIPv4Address(baseIPAddress)
+	if numericIP == 0 {
+		return ipv4Child, 0, 0, false
 	}
 
-	numericIP = parseIPv6Address(baseIPAddress)
+	return ipv4Child, uint64(numericIP) << ipv4BitCount, subnetBits, true
+}
+func parseIPv6SubnetMask(subnetMask string) (int, uint64, int, bool) {
+	subnetBits, baseIPAddress := prepareBaseIPAndSubnetMask(subnetMask)
+	if subnetBits <= 0 || len(baseIPAddress) == 0 {
+		return ipv6Child, 0, 0, false
+	}
+
+	if !containsValidHexValues(baseIPAddress) {
+		return ipv6Child, 0, 0, false
+	}
 
+	numericIP := parseIPv6Address(baseIPAddress)
 	if numericIP == 0 {
-		return
+		return ipv6Child, 0, 0, false
 	}
 
-	if subnetBits > 64 {
-		subnetBits = 64
+	if subnetBits > ipv6BitCount {
+		subnetBits = ipv6BitCount
 	}
 
-	current := this.children[ipv6Child]
-	for i := 0; i < subnetBits; i++ {
-		nextBit := uint32(numericIP << i >> ipv6BitMask)
-		child := current.children[nextBit]
+	return ipv6Child, numericIP, subnetBits, true
+}
+func parseAddress(ipAddress string) (int, uint64, bool) {
+	if len(ipAddress) == 0 {
+		return ipv4Child, 0, false
+	}
 
-		if child == nil {
-			child = newNode()
-			current.children[nextBit] = child
-		}
-		current = child
+	if strings.Contains(ipAddress, ":") {
+		numericIP := parseIPv6Address(ipAddress)
+		return ipv6Child, numericIP, numericIP != 0
+	} else {
+		numericIP := parseIPv4Address(ipAddress)
+		return ipv4Child, uint64(numericIP) << ipv4BitCount, numericIP != 0
+	}
+}
+func parseAddr(address netip.Addr) (int, uint64, bool) {
+	if address.Is4() {
+		raw := address.As4()
+		numericIP := uint64(binary.BigEndian.Uint32(raw[:])) << ipv4BitCount
+		return ipv4Child, numericIP, numericIP != 0
 	}
 
-	current.banned = true
+	if address.Is6() {
+		raw := address.As16()
+		numericIP := binary.BigEndian.Uint64(raw[:8])
+		return ipv6Child, numericIP, numericIP != 0
+	}
+
+	return ipv4Child, 0, false
+}
+func parsePrefix(prefix netip.Prefix) (int, uint64, int, bool) {
+	family, numericIP, ok := parseAddr(prefix.Addr())
+	subnetBits := prefix.Bits()
+	if !ok || subnetBits <= 0 {
+		return family, 0, 0, false
+	}
+
+	return family, numericIP, min(subnetBits, numericBitCount), true
+}
+func formatPrefix(family int, numericIP uint64, subnetBits int) netip.Prefix {
+	numericIP = maskNumericIP(numericIP, subnetBits)
+
+	if family == ipv4Child {
+		var raw [4]byte
+		binary.BigEndian.PutUint32(raw[:], uint32(numericIP>>ipv4BitCount))
+		return netip.PrefixFrom(netip.AddrFrom4(raw), subnetBits)
+	}
+
+	var raw [16]byte
+	binary.BigEndian.PutUint64(raw[:8], numericIP)
+	return netip.PrefixFrom(netip.AddrFrom16(raw), subnetBits)
 }
 
 func prepareBaseIPAndSubnetMask(subnetMask string) (int, string) {
@@ -204,29 +249,17 @@ func parseIPv6Address(value string) uint64 {
 }
 
 func (this *treeNode) Contains(ipAddress string) bool {
-	if len(ipAddress) == 0 {
-		return false
-	}
-
-	if strings.Contains(ipAddress, ":") {
-		return this.containsIPv6(ipAddress)
-	} else {
-		return this.containsIPv4(ipAddress)
-	}
+	family, numericIP, ok := parseAddress(ipAddress)
+	return ok && this.contains(family, numericIP)
 }
-func (this *treeNode) containsIPv4(ipAddress string) bool {
-	var numericIP uint32
-
-	numericIP = parseIPv4Address(ipAddress)
-
-	if numericIP == 0 {
-		return false
-	}
-
-	current := this.children[ipv4Child]
-	for i := 0; i < ipv4BitCount; i++ {
-		nextBit := uint32(numericIP << i >> ipv4BitMask)
-		child := current.children[nextBit]
+func (this *treeNode) contains(family int, numericIP uint64) bool {
+	_, matched := this.match(family, numericIP)
+	return matched
+}
+func (this *treeNode) match(family int, numericIP uint64) (int, bool) {
+	current := this.children[family]
+	for i := 0; i < numericBitCount; i++ {
+		child := current.children[numericIP<<i>>numericBitMask]
 
 		if child == nil {
 			break
@@ -234,43 +267,38 @@ func (this *treeNode) containsIPv4(ipAddress string) bool {
 
 		current = child
 		if current.banned {
-			return true
+			return i + 1, true
 		}
 	}
 
-	return false
+	return 0, false
 }
-func (this *treeNode) containsIPv6(ipAddress string) bool {
-	var numericIP uint64
-
-	numericIP = parseIPv6Address(ipAddress)
 
-	if numericIP == 0 {
-		return false
+func (this *treeNode) Stats() (stats Stats) {
+	for family, child := range this.children {
+		child.stats(family, &stats)
 	}
 
-	current := this.children[1]
-	for i := 0; i < ipv6BitCount; i++ {
-		nextBit := uint32(numericIP << i >> ipv6BitMask)
-		child := current.children[nextBit]
-
-		if child == nil {
-			break
-		}
+	stats.Nodes++ // the root
+	stats.Bytes = stats.Nodes * int(unsafe.Sizeof(treeNode{})+2*unsafe.Sizeof(this))
+	return stats
+}
+func (this *treeNode) stats(family int, stats *Stats) {
+	stats.Nodes++
+	if this.banned {
+		stats.addRule(family)
+	}
 
-		current = child
-		if current.banned {
-			return true
+	for _, child := range this.children {
+		if child != nil {
+			child.stats(family, stats)
 		}
 	}
-
-	return false
 }
 
 const (
 	decimalNumber       = 10
 	ipv4BitCount        = 32
-	ipv4BitMask         = ipv4BitCount - 1
 	octetBits           = 8
 	octetSeparatorCount = 3
 	octetCount          = 4
@@ -282,7 +310,10 @@ const (
 	hexadecimalBitCount     = 16
 	ipv6Separator           = ':'
 	ipv6BitCount            = 64
-	ipv6BitMask             = ipv6BitCount - 1
+
+	// both families are stored left-aligned in a uint64
+	numericBitCount = 64
+	numericBitMask  = numericBitCount - 1
 
 	ipv4Child = 0
 	ipv6Child = 1
@@ -0,0 +1,107 @@
+package ipfilter
+
+// valueTrie is a path-compressed trie that keeps a value for every inserted prefix. Unlike compressedTree it keeps
+// prefixes nested below other prefixes, so a lookup can visit every rule that contains an address.
+type valueTrie[T any] struct {
+	roots [2]*valueNode[T]
+}
+type valueNode[T any] struct {
+	children   [2]*valueNode[T]
+	numericIP  uint64
+	subnetBits uint8
+	hasValue   bool
+	value      T
+}
+
+// insert returns the value slot of the prefix, creating it when needed; created reports whether it is new.
+func (this *valueTrie[T]) insert(family int, numericIP uint64, subnetBits int) (value *T, created bool) {
+	numericIP = maskNumericIP(numericIP, subnetBits)
+	link := &this.roots[family]
+
+	for {
+		node := *link
+		if node == nil {
+			node = &valueNode[T]{numericIP: numericIP, subnetBits: uint8(subnetBits)}
+			*link = node
+		}
+
+		nodeBits := int(node.subnetBits)
+		common := commonPrefixBits(node.numericIP, numericIP, min(nodeBits, subnetBits))
+
+		if common < nodeBits {
+			parent := &valueNode[T]{numericIP: maskNumericIP(numericIP, common), subnetBits: uint8(common)}
+			parent.children[node.numericIP<<common>>numericBitMask] = node
+			*link = parent
+			continue // the parent either is the prefix (common == subnetBits) or gets it as a new child
+		}
+
+		if nodeBits == subnetBits {
+			created = !node.hasValue
+			node.hasValue = true
+			return &node.value, created
+		}
+
+		link = &node.children[numericIP<<nodeBits>>numericBitMask]
+	}
+}
+
+// walk visits the prefixes containing the address from shortest to longest until visit returns false.
+func (this *valueTrie[T]) walk(family int, numericIP uint64, visit func(subnetBits int, value *T) bool) {
+	for current := this.roots[family]; current != nil; {
+		if maskNumericIP(numericIP, int(current.subnetBits)) != current.numericIP {
+			return
+		}
+
+		if current.hasValue && !visit(int(current.subnetBits), &current.value) {
+			return
+		}
+
+		if current.subnetBits == numericBitCount {
+			return
+		}
+
+		current = current.children[numericIP<<current.subnetBits>>numericBitMask]
+	}
+}
+
+// each visits every prefix in ascending address order, shorter prefixes before the prefixes they contain.
+func (this *valueTrie[T]) each(visit func(family int, numericIP uint64, subnetBits int, value *T)) {
+	for family, root := range this.roots {
+		root.each(family, visit)
+	}
+}
+func (this *valueNode[T]) each(family int, visit func(int, uint64, int, *T)) {
+	if this == nil {
+		return
+	}
+
+	if this.hasValue {
+		visit(family, this.numericIP, int(this.subnetBits), &this.value)
+	}
+
+	this.children[0].each(family, visit)
+	this.children[1].each(family, visit)
+}
+
+// stats counts nodes and values; size is the size of a node.
+func (this *valueTrie[T]) stats(size int) (stats Stats) {
+	for family, root := range this.roots {
+		root.stats(family, &stats)
+	}
+
+	stats.Bytes = stats.Nodes * size
+	return stats
+}
+func (this *valueNode[T]) stats(family int, stats *Stats) {
+	if this == nil {
+		return
+	}
+
+	stats.Nodes++
+	if this.hasValue {
+		stats.addRule(family)
+	}
+
+	this.children[0].stats(family, stats)
+	this.children[1].stats(family, stats)
+}